@@ -5,7 +5,7 @@ package docs
 import "github.com/swaggo/swag/v2"
 
 const docTemplate = `{
-    "schemes": {{ marshal .Schemes }},"swagger":"2.0","info":{"description":"{{escape .Description}}","title":"{{.Title}}","contact":{},"version":"{{.Version}}"},"host":"{{.Host}}","basePath":"{{.BasePath}}","paths":{"/resources":{"get":{"description":"Get Helm chart resources","produces":["application/json"],"summary":"Get Helm chart resources","operationId":"get-chart-resources","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true}],"responses":{"200":{"description":"OK","schema":{"type":"array","items":{"$ref":"#/definitions/resources.Resource"}}}}}}},"definitions":{"resources.Resource":{"type":"object","properties":{"group":{"type":"string"},"name":{"type":"string"},"namespace":{"type":"string"},"resource":{"type":"string"},"version":{"type":"string"}}}}}`
+    "schemes": {{ marshal .Schemes }},"swagger":"2.0","info":{"description":"{{escape .Description}}","title":"{{.Title}}","contact":{},"version":"{{.Version}}"},"host":"{{.Host}}","basePath":"{{.BasePath}}","paths":{"/rbac":{"get":{"description":"Get the Roles, ClusterRole and bindings a ServiceAccount needs to install the chart of a composition. A POST also applies them to the cluster with server-side apply, when the service allows it","produces":["application/json","application/yaml"],"summary":"Get the RBAC policy of a Helm chart","operationId":"get-chart-rbac","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"type":"string","description":"Name of the ServiceAccount the roles are bound to","name":"serviceAccountName","in":"query","required":true},{"type":"string","description":"Namespace of the ServiceAccount (default: the composition definition namespace)","name":"serviceAccountNamespace","in":"query"},{"type":"string","description":"Name of the generated roles and bindings (default: chart-inspector:<compositionDefinitionNamespace>:<compositionDefinitionName>)","name":"name","in":"query"},{"enum":["exact","resource","group"],"type":"string","default":"resource","description":"How the rules are built: by object name with resourceNames, per resource, or per API group","name":"strategy","in":"query"},{"type":"integer","default":0,"description":"Maximum number of rules of a role, 0 for no limit: a role needing more falls back to the next, coarser, strategy","name":"maxRules","in":"query"},{"enum":["yaml","json"],"type":"string","default":"yaml","description":"Response format: a multi-document YAML stream or a JSON v1 List","name":"format","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"}],"responses":{"200":{"description":"The Roles, ClusterRole and bindings, as applied with a POST","schema":{"type":"string"},"headers":{"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the policy lacks some calls"}}},"403":{"description":"A POST while applying is disabled, or the service may not apply the policy","schema":{"type":"string"}},"409":{"description":"Applying the policy conflicts with the fields of another manager, or with existing objects the service did not apply for this composition definition (POST only)","schema":{"type":"string"}},"422":{"description":"The dry-run failed, so the policy would be incomplete, or recording the cassette reached maxCalls or maxBodyBytes","schema":{"type":"string"}}}},"post":{"description":"Get the Roles, ClusterRole and bindings a ServiceAccount needs to install the chart of a composition. A POST also applies them to the cluster with server-side apply, when the service allows it","produces":["application/json","application/yaml"],"summary":"Get the RBAC policy of a Helm chart","operationId":"get-chart-rbac","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"type":"string","description":"Name of the ServiceAccount the roles are bound to","name":"serviceAccountName","in":"query","required":true},{"type":"string","description":"Namespace of the ServiceAccount (default: the composition definition namespace)","name":"serviceAccountNamespace","in":"query"},{"type":"string","description":"Name of the generated roles and bindings (default: chart-inspector:<compositionDefinitionNamespace>:<compositionDefinitionName>)","name":"name","in":"query"},{"enum":["exact","resource","group"],"type":"string","default":"resource","description":"How the rules are built: by object name with resourceNames, per resource, or per API group","name":"strategy","in":"query"},{"type":"integer","default":0,"description":"Maximum number of rules of a role, 0 for no limit: a role needing more falls back to the next, coarser, strategy","name":"maxRules","in":"query"},{"enum":["yaml","json"],"type":"string","default":"yaml","description":"Response format: a multi-document YAML stream or a JSON v1 List","name":"format","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"}],"responses":{"200":{"description":"The Roles, ClusterRole and bindings, as applied with a POST","schema":{"type":"string"},"headers":{"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the policy lacks some calls"}}},"403":{"description":"A POST while applying is disabled, or the service may not apply the policy","schema":{"type":"string"}},"409":{"description":"Applying the policy conflicts with the fields of another manager, or with existing objects the service did not apply for this composition definition (POST only)","schema":{"type":"string"}},"422":{"description":"The dry-run failed, so the policy would be incomplete, or recording the cassette reached maxCalls or maxBodyBytes","schema":{"type":"string"}}}}},"/rbac/diff":{"get":{"description":"Compare the RBAC the chart of a composition needs with the RBAC bound to a ServiceAccount, directly or through its groups: the missing rules, the over-granted rules, the unused bindings and the bindings to its groups","produces":["application/json"],"summary":"Diff the RBAC of a Helm chart against a ServiceAccount","operationId":"diff-chart-rbac","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"type":"string","description":"Name of the ServiceAccount whose bindings are compared","name":"serviceAccountName","in":"query","required":true},{"type":"string","description":"Namespace of the ServiceAccount (default: the composition definition namespace)","name":"serviceAccountNamespace","in":"query"},{"enum":["exact","resource","group"],"type":"string","default":"resource","description":"How the missing rules are built: by object name with resourceNames, per resource, or per API group","name":"strategy","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"}],"responses":{"200":{"description":"The missing rules, the over-granted rules, the unused bindings and the bindings to the groups of the ServiceAccount","schema":{"$ref":"#/definitions/rbac.Diff"},"headers":{"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the diff lacks some calls"}}},"422":{"description":"The dry-run failed, so the needed rules would be incomplete, or recording the cassette reached maxCalls or maxBodyBytes","schema":{"type":"string"}}}}},"/rbac/verify":{"get":{"description":"Run the dry-run of the chart of a composition as if its calls were authorized only by the given rules, and list the calls they deny","consumes":["application/json"],"produces":["application/json"],"summary":"Verify an RBAC policy against a Helm chart","operationId":"verify-chart-rbac","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"type":"array","items":{"type":"string"},"collectionFormat":"csv","description":"Roles of the cluster whose rules are verified, as namespace/name","name":"role","in":"query"},{"type":"array","items":{"type":"string"},"collectionFormat":"csv","description":"ClusterRoles of the cluster whose rules are verified, granted on the whole cluster","name":"clusterRole","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"},{"description":"Rules to verify, each granted in a namespace or, without one, on the whole cluster (POST only)","name":"grants","in":"body","schema":{"$ref":"#/definitions/verify.verifyRequest"}}],"responses":{"200":{"description":"Whether the rules allow the dry-run, and the calls they deny","schema":{"$ref":"#/definitions/rbac.Verification"},"headers":{"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the result lacks some calls"}}},"422":{"description":"Recording the cassette reached maxCalls or maxBodyBytes","schema":{"type":"string"}}}},"post":{"description":"Run the dry-run of the chart of a composition as if its calls were authorized only by the given rules, and list the calls they deny","consumes":["application/json"],"produces":["application/json"],"summary":"Verify an RBAC policy against a Helm chart","operationId":"verify-chart-rbac","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"type":"array","items":{"type":"string"},"collectionFormat":"csv","description":"Roles of the cluster whose rules are verified, as namespace/name","name":"role","in":"query"},{"type":"array","items":{"type":"string"},"collectionFormat":"csv","description":"ClusterRoles of the cluster whose rules are verified, granted on the whole cluster","name":"clusterRole","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"},{"description":"Rules to verify, each granted in a namespace or, without one, on the whole cluster (POST only)","name":"grants","in":"body","schema":{"$ref":"#/definitions/verify.verifyRequest"}}],"responses":{"200":{"description":"Whether the rules allow the dry-run, and the calls they deny","schema":{"$ref":"#/definitions/rbac.Verification"},"headers":{"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the result lacks some calls"}}},"422":{"description":"Recording the cassette reached maxCalls or maxBodyBytes","schema":{"type":"string"}}}}},"/render":{"get":{"description":"Get the manifest the chart of a composition renders, with the values the composition-dynamic-controller computes, out of the same server-side dry-run as /resources. With hooks or notes, the hooks and the NOTES.txt of the chart, which the release manifest lacks, are rendered with a second dry-run and added to the output","produces":["application/yaml","application/json"],"summary":"Render the Helm chart of a composition","operationId":"get-chart-render","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"enum":["yaml","json"],"type":"string","default":"yaml","description":"Response format: the manifest as a multi-document YAML stream, with the template of each object in a Source comment, or a JSON array of the objects","name":"format","in":"query"},{"type":"boolean","default":false,"description":"Add the hooks of the chart after the manifest: YAML documents with their Source comment, or objects of the JSON array","name":"hooks","in":"query"},{"type":"boolean","default":false,"description":"Add the rendered NOTES.txt of the chart at the end: a YAML comment, or the last element of the JSON array, a string","name":"notes","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"}],"responses":{"200":{"description":"The rendered manifest","schema":{"type":"string"}},"422":{"description":"The dry-run failed, so nothing was rendered, or recording the cassette reached maxCalls or maxBodyBytes","schema":{"type":"string"}}}}},"/resources":{"get":{"description":"Get Helm chart resources","produces":["application/json"],"summary":"Get Helm chart resources","operationId":"get-chart-resources","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true},{"type":"boolean","default":false,"description":"Collapse duplicate entries, merging their verbs and counting the calls","name":"aggregate","in":"query"},{"type":"array","items":{"enum":["rendered","lookup","discovery","helm"],"type":"string"},"collectionFormat":"csv","description":"Only return entries of these origins","name":"origin","in":"query"},{"enum":["list","report","har"],"type":"string","default":"list","description":"Response format: the plain list of resources, a report that also lists the lookups that found nothing, the forbidden calls, the API warnings and the admission rejections, or the full HTTP exchange with the API server as HAR 1.2","name":"output","in":"query"},{"type":"boolean","default":true,"description":"With output=har, redact the data of Secrets. The Authorization, Cookie and Impersonate-* headers are always redacted","name":"redact","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with output=har or cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"},{"type":"boolean","default":false,"description":"Union the traced resources with the objects of the rendered release manifest, marking where each entry was seen","name":"merge","in":"query"},{"type":"boolean","default":false,"description":"Time the phases of the request and the API calls of the dry-run: a timings object in the report (output=report) and a Server-Timing header","name":"timings","in":"query"}],"responses":{"200":{"description":"The traced resources, or a resources.Report when output=report","schema":{"type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"headers":{"Server-Timing":{"type":"string","description":"Durations of the phases of the request, with timings=true"},"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the result lacks some calls"}}},"422":{"description":"The dry-run failed, e.g. because admission rejected an object (output=report or output=har only), or recording the cassette reached maxCalls or maxBodyBytes","schema":{"$ref":"#/definitions/resources.Report"},"headers":{"Server-Timing":{"type":"string","description":"Durations of the phases of the request, with timings=true"},"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the result lacks some calls"}}}}},"post":{"description":"Get the Helm chart resources of a Composition and a CompositionDefinition posted in the body, which need not exist in the cluster. The query parameters are those of the GET; the composition ones identify the Composition built out of values when the body has no composition","consumes":["application/json"],"produces":["application/json"],"summary":"Get the Helm chart resources of inline objects","operationId":"post-chart-resources","parameters":[{"type":"string","description":"Composition name, when the body has no composition","name":"compositionName","in":"query"},{"type":"string","description":"Composition namespace, when the body has no composition","name":"compositionNamespace","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group, when the body has no composition","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version, when the body has no composition","name":"compositionVersion","in":"query"},{"type":"string","description":"Composition resource name (default: the pluralized kind of the composition in the body)","name":"compositionResource","in":"query"},{"type":"boolean","default":false,"description":"Collapse duplicate entries, merging their verbs and counting the calls","name":"aggregate","in":"query"},{"type":"array","items":{"enum":["rendered","lookup","discovery","helm"],"type":"string"},"collectionFormat":"csv","description":"Only return entries of these origins","name":"origin","in":"query"},{"enum":["list","report","har"],"type":"string","default":"list","description":"Response format, as for the GET","name":"output","in":"query"},{"type":"boolean","default":true,"description":"With output=har, redact the data of Secrets. The Authorization, Cookie and Impersonate-* headers are always redacted","name":"redact","in":"query"},{"type":"string","description":"Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service","name":"impersonate","in":"query"},{"enum":["record","replay"],"type":"string","description":"Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server","name":"cassette","in":"query"},{"type":"integer","description":"Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxCalls","in":"query"},{"type":"integer","description":"Maximum number of body bytes captured with output=har or cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached","name":"maxBodyBytes","in":"query"},{"type":"boolean","default":false,"description":"Union the traced resources with the objects of the rendered release manifest, marking where each entry was seen","name":"merge","in":"query"},{"type":"boolean","default":false,"description":"Time the phases of the request and the API calls of the dry-run: a timings object in the report (output=report) and a Server-Timing header","name":"timings","in":"query"},{"description":"The composition or the values of the chart, and the composition definition or the chart reference","name":"objects","in":"body","required":true,"schema":{"$ref":"#/definitions/inspector.Inline"}}],"responses":{"200":{"description":"The traced resources, or a resources.Report when output=report","schema":{"type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"headers":{"Server-Timing":{"type":"string","description":"Durations of the phases of the request, with timings=true"},"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the result lacks some calls"}}},"422":{"description":"The dry-run failed, e.g. because admission rejected an object (output=report or output=har only), or recording the cassette reached maxCalls or maxBodyBytes","schema":{"$ref":"#/definitions/resources.Report"},"headers":{"Server-Timing":{"type":"string","description":"Durations of the phases of the request, with timings=true"},"X-Chart-Inspector-Truncated":{"type":"string","description":"Set to true when the tracer reached its limits and the result lacks some calls"}}}}}},"/values":{"get":{"description":"Get the values the chart of a composition is rendered with: its spec, with the global values the composition-dynamic-controller injects, coalesced with the chart defaults. Each value is annotated with its source, and those that look sensitive are redacted. The chart is not installed, so the dry-run parameters of the other endpoints (impersonate, cassette, maxCalls, maxBodyBytes) are refused with 400","produces":["application/json"],"summary":"Get the values of the Helm chart of a composition","operationId":"get-chart-values","parameters":[{"type":"string","description":"Composition name","name":"compositionName","in":"query","required":true},{"type":"string","description":"Composition namespace","name":"compositionNamespace","in":"query","required":true},{"type":"string","description":"Composition definition name","name":"compositionDefinitionName","in":"query","required":true},{"type":"string","description":"Composition definition namespace","name":"compositionDefinitionNamespace","in":"query","required":true},{"type":"string","default":"core.krateo.io","description":"Composition definition group","name":"compositionDefinitionGroup","in":"query"},{"type":"string","default":"v1alpha1","description":"Composition definition version","name":"compositionDefinitionVersion","in":"query"},{"type":"string","default":"compositiondefinitions","description":"Composition definition resource name","name":"compositionDefinitionResource","in":"query"},{"type":"string","default":"composition.krateo.io","description":"Composition group","name":"compositionGroup","in":"query"},{"type":"string","description":"Composition version","name":"compositionVersion","in":"query","required":true},{"type":"string","description":"Composition resource name","name":"compositionResource","in":"query","required":true}],"responses":{"200":{"description":"The values, with the source of each of them","schema":{"$ref":"#/definitions/values.Annotated"}}}}}},"definitions":{"inspector.Inline":{"type":"object","properties":{"chart":{"description":"Chart is the chart to install, when CompositionDefinition is not set.","allOf":[{"$ref":"#/definitions/v1alpha1.ChartInfo"}]},"composition":{"description":"Composition is the Composition to inspect. Without it, one is built\nwith Values as its spec and the composition query parameters as its\nidentity.","type":"object","additionalProperties":{}},"compositionDefinition":{"description":"CompositionDefinition is the CompositionDefinition whose chart is\ninstalled. Without it, one is built out of Chart.","type":"object","additionalProperties":{}},"values":{"description":"Values are the values of the chart, when Composition is not set.","type":"object","additionalProperties":{}}}},"rbac.BindingRef":{"type":"object","properties":{"group":{"description":"Group is the group of the ServiceAccount the binding names.","type":"string"},"kind":{"type":"string"},"name":{"type":"string"},"namespace":{"type":"string"},"roleRef":{"$ref":"#/definitions/v1.RoleRef"}}},"rbac.Diff":{"type":"object","properties":{"groupBindings":{"description":"GroupBindings are the bindings naming a group of the ServiceAccount.","type":"array","items":{"$ref":"#/definitions/rbac.BindingRef"}},"missing":{"description":"Missing are the rules to grant for the bound RBAC to allow all the\nneeded calls.","type":"array","items":{"$ref":"#/definitions/rbac.Grant"}},"overGranted":{"description":"OverGranted are the bound rules granting more than needed.","type":"array","items":{"$ref":"#/definitions/rbac.OverGrant"}},"truncated":{"description":"Truncated is set when the tracer reached its limits.","type":"boolean"},"unusedBindings":{"description":"UnusedBindings are the bindings none of whose rules allows a needed\ncall.","type":"array","items":{"$ref":"#/definitions/rbac.BindingRef"}}}},"rbac.Grant":{"type":"object","properties":{"namespace":{"type":"string"},"rules":{"type":"array","items":{"$ref":"#/definitions/v1.PolicyRule"}}}},"rbac.OverGrant":{"type":"object","properties":{"binding":{"$ref":"#/definitions/rbac.BindingRef"},"extraAPIGroups":{"description":"ExtraAPIGroups, ExtraResources and ExtraVerbs are the entries of a\nused rule no needed call it allows matches. Wildcards are always\nreported: they grant whatever the API server serves.","type":"array","items":{"type":"string"}},"extraResources":{"type":"array","items":{"type":"string"}},"extraVerbs":{"type":"array","items":{"type":"string"}},"rule":{"$ref":"#/definitions/v1.PolicyRule"},"unused":{"description":"Unused is set when the rule allows none of the needed calls.","type":"boolean"}}},"rbac.Verification":{"type":"object","properties":{"allowed":{"description":"Allowed is set when the dry-run completed without making any call the\ngrants deny.","type":"boolean"},"denied":{"description":"Denied are the calls the grants deny, aggregated.","type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"error":{"description":"Error is why the dry-run failed, if it did. A denied call usually\nmakes it fail, e.g. a forbidden lookup fails the template.","type":"string"},"truncated":{"description":"Truncated is set when the tracer reached its limits.","type":"boolean"}}},"resources.APITiming":{"type":"object","properties":{"count":{"type":"integer"},"durationMs":{"type":"number"},"origin":{"type":"string"}}},"resources.Call":{"type":"object","properties":{"durationMs":{"description":"DurationMs is the time the API server took to answer the call, in\nmilliseconds.","type":"number"},"method":{"type":"string"},"origin":{"type":"string"},"start":{"type":"string"},"status":{"type":"integer"},"uri":{"description":"URI is the path and query of the call.","type":"string"},"verb":{"description":"Verb is the RBAC verb of the call, empty for a discovery call that does\nnot target a resource.","type":"string"}}},"resources.Phase":{"type":"object","properties":{"durationMs":{"type":"number"},"name":{"type":"string"}}},"resources.Report":{"type":"object","properties":{"admission":{"description":"Admission lists the writes rejected by admission: the Composition\nwould be rejected when the CDC applies it.","type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"error":{"description":"Error is the reason the dry-run failed, if it did. The report then\ncovers the calls made until the failure.","type":"string"},"forbidden":{"description":"Forbidden lists the calls answered with 403 Forbidden: the identity\nrunning the dry-run is not allowed to see or change them.","type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"notFound":{"description":"NotFound lists the lookups answered with 404 Not Found, e.g. a template\nlookup that returned an empty object.","type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"resources":{"type":"array","items":{"$ref":"#/definitions/resources.Resource"}},"timings":{"description":"Timings breaks down the time spent serving the request. It is only set\nwhen requested.","allOf":[{"$ref":"#/definitions/resources.Timings"}]},"truncated":{"description":"Truncated is set when the tracer reached its limits: the report then\nlacks the calls made past them.","type":"boolean"},"warnings":{"description":"Warnings lists the calls the API server answered with warnings.","type":"array","items":{"$ref":"#/definitions/resources.Resource"}}}},"resources.Resource":{"type":"object","properties":{"admission":{"description":"Admission is the message of the rejection of a write by admission\n(webhooks, ValidatingAdmissionPolicies, built-in admission plugins).","type":"string"},"count":{"description":"Count is the number of traced calls merged into this entry. It is only\nset on aggregated results.","type":"integer"},"group":{"type":"string"},"kind":{"description":"Kind is the kind of the object, as resolved through discovery or, for\na resource unknown to discovery, as carried in the body of the call\n(e.g. the manifest sent by a dry-run create).","type":"string"},"name":{"type":"string"},"namespace":{"type":"string"},"namespaced":{"description":"Namespaced tells whether the resource is namespaced or cluster-scoped.\nIt is unset when the resource is unknown to the API server.","type":"boolean"},"origin":{"description":"Origin is the category of the call, one of Origins.","type":"string"},"resource":{"type":"string"},"seen":{"description":"Seen tells whether the entry was seen in the traffic of the dry-run, in\nthe release manifest or in both. It is only set when they are merged.","type":"string"},"source":{"description":"Source is the path of the template that rendered the object, as found\nin the release manifest.","type":"string"},"status":{"description":"Status is the HTTP status code the API server answered the call with,\nor zero when the call failed before a response was received.","type":"integer"},"subchart":{"description":"Subchart is the subchart the template belongs to, empty for a template\nof the chart itself. Nested subcharts are joined with a slash.","type":"string"},"subresource":{"type":"string"},"template":{"description":"Template is the path of the template that rendered the object within\nits chart, e.g. \"templates/service.yaml\".","type":"string"},"verbs":{"type":"array","items":{"type":"string"}},"version":{"type":"string"},"warnings":{"description":"Warnings are the Warning headers the API server answered the call with:\ndeprecation notices and warnings from admission webhooks and policies.","type":"array","items":{"type":"string"}}}},"resources.Timings":{"type":"object","properties":{"api":{"description":"API sums the API calls of the dry-run by origin. The install phase\nminus their total is the time spent downloading and rendering the\nchart.","type":"array","items":{"$ref":"#/definitions/resources.APITiming"}},"calls":{"description":"Calls lists every API call of the dry-run.","type":"array","items":{"$ref":"#/definitions/resources.Call"}},"phases":{"description":"Phases are the phases of the request, in order, ending with the total.","type":"array","items":{"$ref":"#/definitions/resources.Phase"}}}},"v1.PolicyRule":{"type":"object","properties":{"apiGroups":{"description":"APIGroups is the name of the APIGroup that contains the resources.  If multiple API groups are specified, any action requested against one of\nthe enumerated resources in any API group will be allowed. \"\" represents the core API group and \"*\" represents all API groups.","type":"array","items":{"type":"string"}},"nonResourceURLs":{"description":"NonResourceURLs is a set of partial urls that a user should have access to.  *s are allowed, but only as the full, final step in the path\nSince non-resource URLs are not namespaced, this field is only applicable for ClusterRoles referenced from a ClusterRoleBinding.\nRules can either apply to API resources (such as \"pods\" or \"secrets\") or non-resource URL paths (such as \"/api\"),  but not both.","type":"array","items":{"type":"string"}},"resourceNames":{"description":"ResourceNames is an optional white list of names that the rule applies to.  An empty set means that everything is allowed.","type":"array","items":{"type":"string"}},"resources":{"description":"Resources is a list of resources this rule applies to. '*' represents all resources.","type":"array","items":{"type":"string"}},"verbs":{"description":"Verbs is a list of Verbs that apply to ALL the ResourceKinds contained in this rule. '*' represents all verbs.","type":"array","items":{"type":"string"}}}},"v1.RoleRef":{"type":"object","properties":{"apiGroup":{"description":"APIGroup is the group for the resource being referenced","type":"string"},"kind":{"description":"Kind is the type of resource being referenced","type":"string"},"name":{"description":"Name is the name of resource being referenced","type":"string"}}},"v1.SecretKeySelector":{"type":"object","properties":{"key":{"description":"The key to select.","type":"string"},"name":{"description":"Name of the referenced object.","type":"string"},"namespace":{"description":"Namespace of the referenced object.","type":"string"}}},"v1alpha1.ChartInfo":{"type":"object","properties":{"credentials":{"description":"Credentials: credentials for private repos","allOf":[{"$ref":"#/definitions/v1alpha1.Credentials"}]},"insecureSkipVerifyTLS":{"description":"InsecureSkipVerifyTLS: skip tls verification","type":"boolean"},"repo":{"description":"Repo: helm repo name (for helm repo urls only)","type":"string","maxLength":256},"url":{"description":"Url: oci or tgz full url","type":"string"},"version":{"description":"Version: desired chart version, needed for oci charts and for helm repo urls","type":"string","maxLength":20}}},"v1alpha1.Credentials":{"type":"object","properties":{"passwordRef":{"description":"PasswordRef: reference to secret containing password for private repo","allOf":[{"$ref":"#/definitions/v1.SecretKeySelector"}]},"username":{"description":"Username: username for private repo","type":"string"}}},"values.Annotated":{"type":"object","properties":{"redacted":{"description":"Redacted are the paths of the redacted values, sorted.","type":"array","items":{"type":"string"}},"sources":{"description":"Sources is the source of every value, by path. A path joins the keys\nleading to the value with dots, escaping the dots of a key with a\nbackslash, as helm --set does. Lists are values as a whole.","type":"object","additionalProperties":{"type":"string"}},"values":{"description":"Values are the values, with those that look sensitive replaced by\nRedacted.","type":"object","additionalProperties":{}}}},"verify.verifyRequest":{"type":"object","properties":{"grants":{"type":"array","items":{"$ref":"#/definitions/rbac.Grant"}}}}}}`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
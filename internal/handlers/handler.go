@@ -3,7 +3,10 @@ package handlers
 import (
 	"log/slog"
 
+	"github.com/krateoplatformops/chart-inspector/internal/tracer"
 	helmconfig "github.com/krateoplatformops/plumbing/helm"
+	"github.com/krateoplatformops/plumbing/helm/getter/cache"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
@@ -13,11 +16,30 @@ type pluralizer interface {
 	GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error)
 }
 
+type restMapper interface {
+	KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error)
+	RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error)
+}
+
 type HandlerOptions struct {
 	Log             *slog.Logger
 	DynamicClient   dynamic.Interface
 	KrateoNamespace string
 	Plurarizer      pluralizer
+	RESTMapper      restMapper
 	RestConfig      *rest.Config
 	HelmClient      helmconfig.Client
+	// ChartCache is the cache of the charts pulled without HelmClient, e.g.
+	// for their default values or to render their hooks. Charts are always
+	// pulled when it is nil.
+	ChartCache *cache.DiskCache
+	// CassetteDir is the directory where dry-runs are recorded to and
+	// replayed from. Cassettes are disabled when it is empty.
+	CassetteDir string
+	// Limits are the default limits of the tracer of every inspection. They
+	// can be overridden per request.
+	Limits tracer.Limits
+	// ApplyRBAC enables applying the generated RBAC to the cluster. The
+	// service is read-only when it is unset.
+	ApplyRBAC bool
 }
@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/helper"
+	"github.com/krateoplatformops/chart-inspector/internal/inspector"
+	"github.com/krateoplatformops/chart-inspector/internal/rbac"
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+type handler struct {
+	handlers.HandlerOptions
+}
+
+func DiffRBAC(opts handlers.HandlerOptions) http.Handler {
+	return &handler{
+		HandlerOptions: opts,
+	}
+}
+
+var _ http.Handler = (*handler)(nil)
+
+// @Summary Diff the RBAC of a Helm chart against a ServiceAccount
+// @Description Compare the RBAC the chart of a composition needs with the RBAC bound to a ServiceAccount, directly or through its groups: the missing rules, the over-granted rules, the unused bindings and the bindings to its groups
+// @ID diff-chart-rbac
+// @Param compositionName query string true "Composition name"
+// @Param compositionNamespace query string true "Composition namespace"
+// @Param compositionDefinitionName query string true "Composition definition name"
+// @Param compositionDefinitionNamespace query string true "Composition definition namespace"
+// @Param compositionDefinitionGroup query string false "Composition definition group" default(core.krateo.io)
+// @Param compositionDefinitionVersion query string false "Composition definition version" default(v1alpha1)
+// @Param compositionDefinitionResource query string false "Composition definition resource name" default(compositiondefinitions)
+// @Param compositionGroup query string false "Composition group" default(composition.krateo.io)
+// @Param compositionVersion query string true "Composition version"
+// @Param compositionResource query string true "Composition resource name"
+// @Param serviceAccountName query string true "Name of the ServiceAccount whose bindings are compared"
+// @Param serviceAccountNamespace query string false "Namespace of the ServiceAccount (default: the composition definition namespace)"
+// @Param strategy query string false "How the missing rules are built: by object name with resourceNames, per resource, or per API group" Enums(exact, resource, group) default(resource)
+// @Param impersonate query string false "Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service"
+// @Param cassette query string false "Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server" Enums(record, replay)
+// @Param maxCalls query int false "Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param maxBodyBytes query int false "Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Produce json
+// @Success 200 {object} rbac.Diff "The missing rules, the over-granted rules, the unused bindings and the bindings to the groups of the ServiceAccount"
+// @Header 200 {string} X-Chart-Inspector-Truncated "Set to true when the tracer reached its limits and the diff lacks some calls"
+// @Failure 422 {string} string "The dry-run failed, so the needed rules would be incomplete, or recording the cassette reached maxCalls or maxBodyBytes"
+// @Router /rbac/diff [get]
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := inspector.FromQuery(r, h.HandlerOptions)
+	log := req.Logger(h.Log)
+	if err != nil {
+		log.Error("invalid query parameters", slog.Any("err", err))
+		response.BadRequest(w, err)
+		return
+	}
+
+	saName := r.URL.Query().Get("serviceAccountName")
+	saNamespace := helper.GetQueryParamWithDefault(r, "serviceAccountNamespace", req.CompositionDefinitionNamespace)
+	strategy := helper.GetQueryParamWithDefault(r, "strategy", rbac.StrategyResource)
+	if saName == "" {
+		log.Error("missing serviceAccountName query parameter")
+		response.BadRequest(w, fmt.Errorf("missing required query parameter serviceAccountName"))
+		return
+	}
+	if !slices.Contains(rbac.Strategies, strategy) {
+		log.Error("invalid strategy query parameter", slog.String("strategy", strategy))
+		response.BadRequest(w, fmt.Errorf("invalid strategy %q, must be one of %v", strategy, rbac.Strategies))
+		return
+	}
+
+	log.Info("Handling request to diff rbac")
+
+	bindings, err := rbac.BoundTo(context.Background(), h.DynamicClient, saNamespace, saName, inspector.ServiceAccount(saNamespace, saName).Groups)
+	if err != nil {
+		log.Error("unable to get the bindings of the service account",
+			slog.String("serviceAccount", saNamespace+"/"+saName),
+			slog.Any("err", err),
+		)
+		response.InternalError(w, err)
+		return
+	}
+
+	ins, err := inspector.Run(context.Background(), h.HandlerOptions, req, log)
+	if err != nil {
+		response.Encode(w, response.New(inspector.StatusCode(err), err))
+		return
+	}
+	// A failed dry-run stops at the first error: the needed rules would miss
+	// the calls that come after it.
+	if ins.InstallErr != nil {
+		response.Encode(w, response.New(http.StatusUnprocessableEntity, ins.InstallErr))
+		return
+	}
+	if ins.ManifestErr != nil {
+		response.InternalError(w, ins.ManifestErr)
+		return
+	}
+
+	res := rbac.Compare(ins.Resources, ins.Rendered, bindings, strategy)
+	res.Truncated = ins.Tracer.Truncated()
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.Truncated {
+		log.Warn("the tracer reached its limits, the diff is truncated",
+			slog.Int("maxCalls", req.Limits.MaxCalls),
+			slog.Int("maxBodyBytes", req.Limits.MaxBodyBytes),
+		)
+		w.Header().Set(inspector.HeaderTruncated, "true")
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error("unable to marshal rbac diff",
+			slog.Any("err", err),
+		)
+		response.InternalError(w, err)
+		return
+	}
+
+	log.Info("Successfully handled request to diff rbac",
+		slog.Int("missing", len(res.Missing)),
+		slog.Int("overGranted", len(res.OverGranted)),
+		slog.Int("unusedBindings", len(res.UnusedBindings)),
+		slog.Int("groupBindings", len(res.GroupBindings)),
+	)
+}
@@ -0,0 +1,196 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/helper"
+	"github.com/krateoplatformops/chart-inspector/internal/inspector"
+	"github.com/krateoplatformops/chart-inspector/internal/rbac"
+	"github.com/krateoplatformops/plumbing/http/response"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Values of the format query parameter.
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+var formats = []string{formatYAML, formatJSON}
+
+type handler struct {
+	handlers.HandlerOptions
+}
+
+func GetRBAC(opts handlers.HandlerOptions) http.Handler {
+	return &handler{
+		HandlerOptions: opts,
+	}
+}
+
+var _ http.Handler = (*handler)(nil)
+
+// @Summary Get the RBAC policy of a Helm chart
+// @Description Get the Roles, ClusterRole and bindings a ServiceAccount needs to install the chart of a composition. A POST also applies them to the cluster with server-side apply, when the service allows it
+// @ID get-chart-rbac
+// @Param compositionName query string true "Composition name"
+// @Param compositionNamespace query string true "Composition namespace"
+// @Param compositionDefinitionName query string true "Composition definition name"
+// @Param compositionDefinitionNamespace query string true "Composition definition namespace"
+// @Param compositionDefinitionGroup query string false "Composition definition group" default(core.krateo.io)
+// @Param compositionDefinitionVersion query string false "Composition definition version" default(v1alpha1)
+// @Param compositionDefinitionResource query string false "Composition definition resource name" default(compositiondefinitions)
+// @Param compositionGroup query string false "Composition group" default(composition.krateo.io)
+// @Param compositionVersion query string true "Composition version"
+// @Param compositionResource query string true "Composition resource name"
+// @Param serviceAccountName query string true "Name of the ServiceAccount the roles are bound to"
+// @Param serviceAccountNamespace query string false "Namespace of the ServiceAccount (default: the composition definition namespace)"
+// @Param name query string false "Name of the generated roles and bindings (default: chart-inspector:<compositionDefinitionNamespace>:<compositionDefinitionName>)"
+// @Param strategy query string false "How the rules are built: by object name with resourceNames, per resource, or per API group" Enums(exact, resource, group) default(resource)
+// @Param maxRules query int false "Maximum number of rules of a role, 0 for no limit: a role needing more falls back to the next, coarser, strategy" default(0)
+// @Param format query string false "Response format: a multi-document YAML stream or a JSON v1 List" Enums(yaml, json) default(yaml)
+// @Param impersonate query string false "Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service"
+// @Param cassette query string false "Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server" Enums(record, replay)
+// @Param maxCalls query int false "Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param maxBodyBytes query int false "Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Produce json
+// @Produce application/yaml
+// @Success 200 {string} string "The Roles, ClusterRole and bindings, as applied with a POST"
+// @Header 200 {string} X-Chart-Inspector-Truncated "Set to true when the tracer reached its limits and the policy lacks some calls"
+// @Failure 403 {string} string "A POST while applying is disabled, or the service may not apply the policy"
+// @Failure 409 {string} string "Applying the policy conflicts with the fields of another manager, or with existing objects the service did not apply for this composition definition (POST only)"
+// @Failure 422 {string} string "The dry-run failed, so the policy would be incomplete, or recording the cassette reached maxCalls or maxBodyBytes"
+// @Router /rbac [get]
+// @Router /rbac [post]
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		response.MethodNotAllowed(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	apply := r.Method == http.MethodPost
+	if apply && !h.ApplyRBAC {
+		h.Log.Error("applying rbac is disabled")
+		response.Forbidden(w, fmt.Errorf("applying rbac is disabled, start the service with APPLY_RBAC=true to enable it"))
+		return
+	}
+
+	format := helper.GetQueryParamWithDefault(r, "format", formatYAML)
+
+	req, err := inspector.FromQuery(r, h.HandlerOptions)
+	log := req.Logger(h.Log)
+	if err != nil {
+		log.Error("invalid query parameters", slog.Any("err", err))
+		response.BadRequest(w, err)
+		return
+	}
+
+	opts := rbac.Options{
+		ServiceAccountName:      r.URL.Query().Get("serviceAccountName"),
+		ServiceAccountNamespace: helper.GetQueryParamWithDefault(r, "serviceAccountNamespace", req.CompositionDefinitionNamespace),
+	}
+	opts.Name = helper.GetQueryParamWithDefault(r, "name", rbac.Name(req.CompositionDefinitionNamespace, req.CompositionDefinitionName))
+	opts.Strategy = helper.GetQueryParamWithDefault(r, "strategy", rbac.StrategyResource)
+	opts.MaxRules, err = helper.GetQueryParamInt(r, "maxRules", 0)
+	if err != nil || opts.MaxRules < 0 {
+		log.Error("invalid maxRules query parameter", slog.String("maxRules", r.URL.Query().Get("maxRules")))
+		response.BadRequest(w, fmt.Errorf("invalid maxRules %q, must be a non-negative integer", r.URL.Query().Get("maxRules")))
+		return
+	}
+	if opts.ServiceAccountName == "" {
+		log.Error("missing serviceAccountName query parameter")
+		response.BadRequest(w, fmt.Errorf("missing required query parameter serviceAccountName"))
+		return
+	}
+
+	if !slices.Contains(rbac.Strategies, opts.Strategy) {
+		log.Error("invalid strategy query parameter", slog.String("strategy", opts.Strategy))
+		response.BadRequest(w, fmt.Errorf("invalid strategy %q, must be one of %v", opts.Strategy, rbac.Strategies))
+		return
+	}
+
+	if !slices.Contains(formats, format) {
+		log.Error("invalid format query parameter", slog.String("format", format))
+		response.BadRequest(w, fmt.Errorf("invalid format %q, must be one of %v", format, formats))
+		return
+	}
+
+	log.Info("Handling request to get rbac")
+
+	ins, err := inspector.Run(context.Background(), h.HandlerOptions, req, log)
+	if err != nil {
+		response.Encode(w, response.New(inspector.StatusCode(err), err))
+		return
+	}
+	// A failed dry-run stops at the first error: the policy would miss the
+	// calls that come after it.
+	if ins.InstallErr != nil {
+		response.Encode(w, response.New(http.StatusUnprocessableEntity, ins.InstallErr))
+		return
+	}
+	if ins.ManifestErr != nil {
+		response.InternalError(w, ins.ManifestErr)
+		return
+	}
+
+	policy := rbac.Generate(ins.Resources, ins.Rendered, opts)
+	if apply {
+		// The objects are deleted with the CompositionDefinition.
+		policy.Own(ins.CompositionDefinition, req.CompositionDefinitionGVR)
+		if err := policy.Apply(context.Background(), h.DynamicClient); err != nil {
+			log.Error("unable to apply rbac",
+				slog.Any("err", err),
+			)
+			switch {
+			case apierrors.IsConflict(err):
+				response.Encode(w, response.New(http.StatusConflict, err))
+			case apierrors.IsForbidden(err):
+				response.Forbidden(w, err)
+			default:
+				response.InternalError(w, err)
+			}
+			return
+		}
+		log.Info("Applied rbac",
+			slog.String("serviceAccount", opts.ServiceAccountNamespace+"/"+opts.ServiceAccountName),
+			slog.Int("objects", len(policy.Objects())),
+		)
+	}
+
+	var body []byte
+	switch format {
+	case formatJSON:
+		var list map[string]any
+		list, err = policy.List()
+		if err == nil {
+			body, err = json.Marshal(list)
+		}
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		body, err = policy.YAML()
+		w.Header().Set("Content-Type", "application/yaml")
+	}
+	if err != nil {
+		log.Error("unable to marshal rbac",
+			slog.Any("err", err),
+		)
+		response.InternalError(w, err)
+		return
+	}
+
+	if ins.Tracer.Truncated() {
+		log.Warn("the tracer reached its limits, the policy is truncated",
+			slog.Int("maxCalls", req.Limits.MaxCalls),
+			slog.Int("maxBodyBytes", req.Limits.MaxBodyBytes),
+		)
+		w.Header().Set(inspector.HeaderTruncated, "true")
+	}
+	w.Write(body)
+
+	log.Info("Successfully handled request to get rbac")
+}
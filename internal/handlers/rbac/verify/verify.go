@@ -0,0 +1,177 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/helper"
+	"github.com/krateoplatformops/chart-inspector/internal/inspector"
+	"github.com/krateoplatformops/chart-inspector/internal/rbac"
+	"github.com/krateoplatformops/plumbing/http/response"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxBodyBytes bounds the size of the rules posted to the endpoint.
+const maxBodyBytes = 1 << 20
+
+// verifyRequest is the body of a POST: the rules to verify.
+type verifyRequest struct {
+	Grants []rbac.Grant `json:"grants"`
+}
+
+type handler struct {
+	handlers.HandlerOptions
+}
+
+func VerifyRBAC(opts handlers.HandlerOptions) http.Handler {
+	return &handler{
+		HandlerOptions: opts,
+	}
+}
+
+var _ http.Handler = (*handler)(nil)
+
+// @Summary Verify an RBAC policy against a Helm chart
+// @Description Run the dry-run of the chart of a composition as if its calls were authorized only by the given rules, and list the calls they deny
+// @ID verify-chart-rbac
+// @Param compositionName query string true "Composition name"
+// @Param compositionNamespace query string true "Composition namespace"
+// @Param compositionDefinitionName query string true "Composition definition name"
+// @Param compositionDefinitionNamespace query string true "Composition definition namespace"
+// @Param compositionDefinitionGroup query string false "Composition definition group" default(core.krateo.io)
+// @Param compositionDefinitionVersion query string false "Composition definition version" default(v1alpha1)
+// @Param compositionDefinitionResource query string false "Composition definition resource name" default(compositiondefinitions)
+// @Param compositionGroup query string false "Composition group" default(composition.krateo.io)
+// @Param compositionVersion query string true "Composition version"
+// @Param compositionResource query string true "Composition resource name"
+// @Param role query []string false "Roles of the cluster whose rules are verified, as namespace/name" collectionFormat(csv)
+// @Param clusterRole query []string false "ClusterRoles of the cluster whose rules are verified, granted on the whole cluster" collectionFormat(csv)
+// @Param impersonate query string false "Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service"
+// @Param cassette query string false "Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server" Enums(record, replay)
+// @Param maxCalls query int false "Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param maxBodyBytes query int false "Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param grants body verifyRequest false "Rules to verify, each granted in a namespace or, without one, on the whole cluster (POST only)"
+// @Accept json
+// @Produce json
+// @Success 200 {object} rbac.Verification "Whether the rules allow the dry-run, and the calls they deny"
+// @Header 200 {string} X-Chart-Inspector-Truncated "Set to true when the tracer reached its limits and the result lacks some calls"
+// @Failure 422 {string} string "Recording the cassette reached maxCalls or maxBodyBytes"
+// @Router /rbac/verify [get]
+// @Router /rbac/verify [post]
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		response.MethodNotAllowed(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	req, err := inspector.FromQuery(r, h.HandlerOptions)
+	log := req.Logger(h.Log)
+	if err != nil {
+		log.Error("invalid query parameters", slog.Any("err", err))
+		response.BadRequest(w, err)
+		return
+	}
+
+	var grants []rbac.Grant
+	if r.Method == http.MethodPost {
+		body := verifyRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&body); err != nil {
+			log.Error("unable to decode rules", slog.Any("err", err))
+			response.BadRequest(w, fmt.Errorf("unable to decode rules: %w", err))
+			return
+		}
+		grants = body.Grants
+	}
+
+	for _, ref := range helper.GetQueryParamList(r, "role") {
+		namespace, name, ok := rbac.ParseRoleRef(ref)
+		if !ok {
+			log.Error("invalid role query parameter", slog.String("role", ref))
+			response.BadRequest(w, fmt.Errorf("invalid role %q, must be namespace/name", ref))
+			return
+		}
+		role := rbacv1.Role{}
+		if err := h.getObject("roles", namespace, name, &role); err != nil {
+			fail(w, log, ref, err)
+			return
+		}
+		grants = append(grants, rbac.Grant{Namespace: namespace, Rules: role.Rules})
+	}
+	for _, name := range helper.GetQueryParamList(r, "clusterRole") {
+		clusterRole := rbacv1.ClusterRole{}
+		if err := h.getObject("clusterroles", "", name, &clusterRole); err != nil {
+			fail(w, log, name, err)
+			return
+		}
+		grants = append(grants, rbac.Grant{Rules: clusterRole.Rules})
+	}
+
+	if len(grants) == 0 {
+		log.Error("no rules to verify")
+		response.BadRequest(w, fmt.Errorf("no rules to verify: post them or name a role or a clusterRole"))
+		return
+	}
+
+	log.Info("Handling request to verify rbac")
+
+	enforcer := rbac.NewEnforcer(grants)
+	req.Transport = func(rt http.RoundTripper) http.RoundTripper {
+		return enforcer.WithRoundTripper(rt)
+	}
+	ins, err := inspector.Run(context.Background(), h.HandlerOptions, req, log)
+	if err != nil {
+		response.Encode(w, response.New(inspector.StatusCode(err), err))
+		return
+	}
+
+	res := enforcer.Verify(ins.InstallErr)
+	res.Truncated = ins.Tracer.Truncated()
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.Truncated {
+		w.Header().Set(inspector.HeaderTruncated, "true")
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error("unable to marshal verification",
+			slog.Any("err", err),
+		)
+		response.InternalError(w, err)
+		return
+	}
+
+	log.Info("Successfully handled request to verify rbac",
+		slog.Bool("allowed", res.Allowed),
+		slog.Int("denied", len(res.Denied)),
+	)
+}
+
+// getObject fetches the RBAC object name of resource into obj.
+func (h *handler) getObject(resource, namespace, name string, obj any) error {
+	gvr := rbacv1.SchemeGroupVersion.WithResource(resource)
+	u, err := h.DynamicClient.Resource(gvr).Namespace(namespace).Get(context.Background(), name, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+}
+
+// fail answers a request naming the RBAC object ref that could not be
+// fetched.
+func fail(w http.ResponseWriter, log *slog.Logger, ref string, err error) {
+	log.Error("unable to get rbac object",
+		slog.String("ref", ref),
+		slog.Any("err", err),
+	)
+	if apierrors.IsNotFound(err) {
+		response.NotFound(w, err)
+		return
+	}
+	response.InternalError(w, err)
+}
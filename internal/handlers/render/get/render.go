@@ -0,0 +1,167 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/helper"
+	"github.com/krateoplatformops/chart-inspector/internal/inspector"
+	"github.com/krateoplatformops/chart-inspector/internal/manifest"
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+// Values of the format query parameter.
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+var formats = []string{formatYAML, formatJSON}
+
+type handler struct {
+	handlers.HandlerOptions
+}
+
+func GetRender(opts handlers.HandlerOptions) http.Handler {
+	return &handler{
+		HandlerOptions: opts,
+	}
+}
+
+var _ http.Handler = (*handler)(nil)
+
+// @Summary Render the Helm chart of a composition
+// @Description Get the manifest the chart of a composition renders, with the values the composition-dynamic-controller computes, out of the same server-side dry-run as /resources. With hooks or notes, the hooks and the NOTES.txt of the chart, which the release manifest lacks, are rendered with a second dry-run and added to the output
+// @ID get-chart-render
+// @Param compositionName query string true "Composition name"
+// @Param compositionNamespace query string true "Composition namespace"
+// @Param compositionDefinitionName query string true "Composition definition name"
+// @Param compositionDefinitionNamespace query string true "Composition definition namespace"
+// @Param compositionDefinitionGroup query string false "Composition definition group" default(core.krateo.io)
+// @Param compositionDefinitionVersion query string false "Composition definition version" default(v1alpha1)
+// @Param compositionDefinitionResource query string false "Composition definition resource name" default(compositiondefinitions)
+// @Param compositionGroup query string false "Composition group" default(composition.krateo.io)
+// @Param compositionVersion query string true "Composition version"
+// @Param compositionResource query string true "Composition resource name"
+// @Param format query string false "Response format: the manifest as a multi-document YAML stream, with the template of each object in a Source comment, or a JSON array of the objects" Enums(yaml, json) default(yaml)
+// @Param hooks query bool false "Add the hooks of the chart after the manifest: YAML documents with their Source comment, or objects of the JSON array" default(false)
+// @Param notes query bool false "Add the rendered NOTES.txt of the chart at the end: a YAML comment, or the last element of the JSON array, a string" default(false)
+// @Param impersonate query string false "Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service"
+// @Param cassette query string false "Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server" Enums(record, replay)
+// @Param maxCalls query int false "Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param maxBodyBytes query int false "Maximum number of body bytes captured with cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Produce application/yaml
+// @Produce json
+// @Success 200 {string} string "The rendered manifest"
+// @Failure 422 {string} string "The dry-run failed, so nothing was rendered, or recording the cassette reached maxCalls or maxBodyBytes"
+// @Router /render [get]
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.MethodNotAllowed(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	format := helper.GetQueryParamWithDefault(r, "format", formatYAML)
+	hooks := helper.GetQueryParamBool(r, "hooks", false)
+	notes := helper.GetQueryParamBool(r, "notes", false)
+
+	req, err := inspector.FromQuery(r, h.HandlerOptions)
+	log := req.Logger(h.Log)
+	if err != nil {
+		log.Error("invalid query parameters", slog.Any("err", err))
+		response.BadRequest(w, err)
+		return
+	}
+
+	if !slices.Contains(formats, format) {
+		log.Error("invalid format query parameter", slog.String("format", format))
+		response.BadRequest(w, fmt.Errorf("invalid format %q, must be one of %v", format, formats))
+		return
+	}
+
+	req.RenderHooks = hooks || notes
+
+	log.Info("Handling request to render chart")
+
+	ins, err := inspector.Run(context.Background(), h.HandlerOptions, req, log)
+	if err != nil {
+		response.Encode(w, response.New(inspector.StatusCode(err), err))
+		return
+	}
+	// Helm returns no release when the dry-run fails.
+	if ins.InstallErr != nil {
+		response.Encode(w, response.New(http.StatusUnprocessableEntity, ins.InstallErr))
+		return
+	}
+
+	stream := ins.Manifest
+	if hooks {
+		stream += hookDocuments(ins.Hooks)
+	}
+
+	var body []byte
+	switch format {
+	case formatJSON:
+		var docs []manifest.Document
+		docs, err = manifest.Documents(stream)
+		if err == nil {
+			elems := make([]any, 0, len(docs)+1)
+			for _, doc := range docs {
+				elems = append(elems, doc.Object)
+			}
+			if notes {
+				elems = append(elems, ins.Notes)
+			}
+			body, err = json.Marshal(elems)
+		}
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		if notes {
+			stream += notesDocument(ins.Notes)
+		}
+		body = []byte(stream)
+		w.Header().Set("Content-Type", "application/yaml")
+	}
+	if err != nil {
+		log.Error("unable to convert the manifest",
+			slog.Any("err", err),
+		)
+		response.InternalError(w, err)
+		return
+	}
+
+	w.Write(body)
+
+	log.Info("Successfully handled request to render chart")
+}
+
+// hookDocuments returns hooks as documents of a release manifest, each
+// preceded by the Source comment naming its template.
+func hookDocuments(hooks []inspector.Hook) string {
+	var sb strings.Builder
+	for _, h := range hooks {
+		fmt.Fprintf(&sb, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+	return sb.String()
+}
+
+// notesDocument returns notes as a document of a YAML stream that only holds
+// comments, so that the stream still parses.
+func notesDocument(notes string) string {
+	var sb strings.Builder
+	sb.WriteString("---\n# NOTES.txt\n")
+	for line := range strings.Lines(strings.TrimRight(notes, "\n")) {
+		if line = strings.TrimSuffix(line, "\n"); line == "" {
+			sb.WriteString("#\n")
+			continue
+		}
+		sb.WriteString("# " + line + "\n")
+	}
+	return sb.String()
+}
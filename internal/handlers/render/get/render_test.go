@@ -0,0 +1,147 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/replaytest"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	demoManifest = `---
+# Source: demo/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: demo-1-settings
+data:
+  greeting: "hi"
+  composition: "demo"
+  database: "demo-db"
+---
+# Source: demo/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: demo-1
+spec:
+  ports:
+    - port: 80
+      targetPort: 8080
+`
+	demoHook = `---
+# Source: demo/templates/greet-job.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: demo-1-greet
+  annotations:
+    "helm.sh/hook": post-install
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+        - name: greet
+          image: busybox
+          command: ["echo", "hi"]
+`
+	demoNotes = `---
+# NOTES.txt
+# hi from demo-1 in demo-system.
+`
+	demoObjects  = `{"apiVersion":"v1","data":{"composition":"demo","database":"demo-db","greeting":"hi"},"kind":"ConfigMap","metadata":{"name":"demo-1-settings"}},{"apiVersion":"v1","kind":"Service","metadata":{"name":"demo-1"},"spec":{"ports":[{"port":80,"targetPort":8080}]}}`
+	demoHookJSON = `{"apiVersion":"batch/v1","kind":"Job","metadata":{"annotations":{"helm.sh/hook":"post-install"},"name":"demo-1-greet"},"spec":{"template":{"spec":{"containers":[{"command":["echo","hi"],"image":"busybox","name":"greet"}],"restartPolicy":"Never"}}}}`
+)
+
+func TestRenderHandlerReplay(t *testing.T) {
+	opts := replaytest.Options(t, &rest.Config{Host: replaytest.Host}, replaytest.CassetteDir())
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       string
+	}{
+		{
+			name:           "manifest",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expected:       demoManifest,
+		},
+		{
+			name:           "hooks",
+			query:          "&hooks=true",
+			expectedStatus: http.StatusOK,
+			expected:       demoManifest + demoHook,
+		},
+		{
+			name:           "hooks and notes",
+			query:          "&hooks=true&notes=true",
+			expectedStatus: http.StatusOK,
+			expected:       demoManifest + demoHook + demoNotes,
+		},
+		{
+			name:           "notes",
+			query:          "&notes=true",
+			expectedStatus: http.StatusOK,
+			expected:       demoManifest + demoNotes,
+		},
+		{
+			name:           "json",
+			query:          "&format=json",
+			expectedStatus: http.StatusOK,
+			expected:       `[` + demoObjects + `]`,
+		},
+		{
+			name:           "json with hooks and notes",
+			query:          "&format=json&hooks=true&notes=true",
+			expectedStatus: http.StatusOK,
+			expected:       `[` + demoObjects + `,` + demoHookJSON + `,"hi from demo-1 in demo-system.\n"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/render?"+replaytest.Query+"&cassette=replay"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			GetRender(opts).ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if got := rec.Body.String(); got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNotesDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		notes    string
+		expected string
+	}{
+		{
+			name:     "empty",
+			notes:    "",
+			expected: "---\n# NOTES.txt\n",
+		},
+		{
+			name:     "blank lines",
+			notes:    "Installed.\n\n  Run:\n    kubectl get pods\n\n",
+			expected: "---\n# NOTES.txt\n# Installed.\n#\n#   Run:\n#     kubectl get pods\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notesDocument(tt.notes); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
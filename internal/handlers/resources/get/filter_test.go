@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+func TestFilterByOrigin(t *testing.T) {
+	li := []resources.Resource{
+		{Resource: "secrets", Origin: resources.OriginHelm},
+		{Resource: "deployments", Origin: resources.OriginRendered},
+		{Resource: "configmaps", Origin: resources.OriginLookup},
+	}
+
+	got := filterByOrigin(li, []string{resources.OriginRendered, resources.OriginLookup})
+	expected := []resources.Resource{
+		{Resource: "deployments", Origin: resources.OriginRendered},
+		{Resource: "configmaps", Origin: resources.OriginLookup},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	if got := filterByOrigin(li, []string{resources.OriginDiscovery}); len(got) != 0 {
+		t.Errorf("expected no entries, got %+v", got)
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	settings := resources.Resource{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "settings", Verbs: []string{"get"}, Origin: resources.OriginLookup}
+	notFound := settings
+	notFound.Status = http.StatusNotFound
+	found := settings
+	found.Status = http.StatusOK
+	forbidden := resources.Resource{Version: "v1", Resource: "nodes", Verbs: []string{"list"}, Origin: resources.OriginLookup, Status: http.StatusForbidden}
+
+	report := newReport([]resources.Resource{notFound, found, forbidden}, false)
+	if len(report.Resources) != 3 {
+		t.Errorf("expected 3 resources, got %d", len(report.Resources))
+	}
+	if !reflect.DeepEqual(report.NotFound, []resources.Resource{notFound}) {
+		t.Errorf("unexpected not found section: %+v", report.NotFound)
+	}
+	if !reflect.DeepEqual(report.Forbidden, []resources.Resource{forbidden}) {
+		t.Errorf("unexpected forbidden section: %+v", report.Forbidden)
+	}
+
+	aggregated := newReport([]resources.Resource{notFound, found, forbidden}, true)
+	if len(aggregated.Resources) != 2 {
+		t.Errorf("expected 2 aggregated resources, got %d", len(aggregated.Resources))
+	}
+	if len(aggregated.NotFound) != 1 || aggregated.NotFound[0].Status != http.StatusNotFound {
+		t.Errorf("expected the lookup to stay reported as not found, got %+v", aggregated.NotFound)
+	}
+
+	empty := newReport(nil, false)
+	if empty.Resources == nil || empty.NotFound == nil || empty.Forbidden == nil || empty.Warnings == nil || empty.Admission == nil {
+		t.Errorf("expected empty sections to be non-nil: %+v", empty)
+	}
+}
+
+func TestNewReportWarningsAndAdmission(t *testing.T) {
+	deprecated := resources.Resource{Group: "policy", Version: "v1beta1", Resource: "poddisruptionbudgets", Namespace: "demo", Verbs: []string{"create"}, Origin: resources.OriginRendered, Status: http.StatusCreated, Warnings: []string{"policy/v1beta1 PodDisruptionBudget is deprecated"}}
+	rejected := resources.Resource{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Verbs: []string{"create"}, Origin: resources.OriginRendered, Status: http.StatusForbidden, Admission: "admission webhook denied the request"}
+
+	report := newReport([]resources.Resource{deprecated, rejected}, false)
+	if !reflect.DeepEqual(report.Warnings, []resources.Resource{deprecated}) {
+		t.Errorf("unexpected warnings section: %+v", report.Warnings)
+	}
+	if !reflect.DeepEqual(report.Admission, []resources.Resource{rejected}) {
+		t.Errorf("unexpected admission section: %+v", report.Admission)
+	}
+	if len(report.Forbidden) != 0 {
+		t.Errorf("expected an admission rejection not to be reported as forbidden, got %+v", report.Forbidden)
+	}
+}
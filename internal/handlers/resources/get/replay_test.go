@@ -0,0 +1,130 @@
+package resources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/replaytest"
+	"k8s.io/client-go/rest"
+)
+
+func demoRequest(query string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/resources?"+replaytest.Query+"&"+query, nil)
+}
+
+func TestResourcesHandlerReplay(t *testing.T) {
+	// Nothing listens on the API server: every call of the dry-run, and every
+	// read of the composition, its definition and the credentials of its
+	// chart, must be served from the cassette. No DynamicClient is set.
+	opts := replaytest.Options(t, &rest.Config{Host: replaytest.Host}, replaytest.CassetteDir())
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       string
+	}{
+		{
+			name:           "replay",
+			query:          "cassette=replay",
+			expectedStatus: http.StatusOK,
+			expected:       `[{"group":"","version":"v1","resource":"secrets","name":"demo-db","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":200},{"group":"","version":"v1","resource":"configmaps","name":"demo-1-settings","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":404,"source":"demo/templates/configmap.yaml","template":"templates/configmap.yaml"},{"group":"","version":"v1","resource":"services","name":"demo-1","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":404,"source":"demo/templates/service.yaml","template":"templates/service.yaml"}]`,
+		},
+		{
+			name:           "report",
+			query:          "cassette=replay&output=report",
+			expectedStatus: http.StatusOK,
+			expected:       `{"resources":[{"group":"","version":"v1","resource":"secrets","name":"demo-db","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":200},{"group":"","version":"v1","resource":"configmaps","name":"demo-1-settings","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":404,"source":"demo/templates/configmap.yaml","template":"templates/configmap.yaml"},{"group":"","version":"v1","resource":"services","name":"demo-1","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":404,"source":"demo/templates/service.yaml","template":"templates/service.yaml"}],"notFound":[{"group":"","version":"v1","resource":"configmaps","name":"demo-1-settings","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":404,"source":"demo/templates/configmap.yaml","template":"templates/configmap.yaml"},{"group":"","version":"v1","resource":"services","name":"demo-1","namespace":"demo-system","verbs":["get"],"origin":"lookup","status":404,"source":"demo/templates/service.yaml","template":"templates/service.yaml"}],"forbidden":[],"warnings":[],"admission":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			GetResources(opts).ServeHTTP(rec, demoRequest(tt.query))
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResourcesHandlerRecord(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectCassette bool
+	}{
+		{
+			name:           "record",
+			query:          "cassette=record&maxCalls=0&maxBodyBytes=0",
+			expectedStatus: http.StatusOK,
+			expectCassette: true,
+		},
+		{
+			name:           "max calls reached",
+			query:          "cassette=record&maxCalls=1",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "max body bytes reached",
+			query:          "cassette=record&maxBodyBytes=1024",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := replaytest.Options(t, &rest.Config{Host: replaytest.Host, Transport: replaytest.Cluster(t)}, dir)
+
+			rec := httptest.NewRecorder()
+			GetResources(opts).ServeHTTP(rec, demoRequest(tt.query))
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			_, err := os.Stat(filepath.Join(dir, replaytest.Cassette))
+			if tt.expectCassette && err != nil {
+				t.Errorf("expected a cassette to be written, got %v", err)
+			}
+			if !tt.expectCassette && !os.IsNotExist(err) {
+				t.Errorf("expected no cassette to be written, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResourcesHandlerReplayMissingCassette(t *testing.T) {
+	opts := replaytest.Options(t, &rest.Config{Host: replaytest.Host}, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	GetResources(opts).ServeHTTP(rec, demoRequest("cassette=replay"))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
+
+func TestResourcesHandlerInvalidComposition(t *testing.T) {
+	opts := replaytest.Options(t, &rest.Config{Host: replaytest.Host}, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	GetResources(opts).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resources?compositionName=..%2Fx&compositionNamespace=demo-system"+
+		"&compositionDefinitionName=demo&compositionDefinitionNamespace=demo-system"+
+		"&compositionVersion=v0-1-0&compositionResource=demos&cassette=record", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+}
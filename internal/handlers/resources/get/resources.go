@@ -6,29 +6,31 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 
-	coreprovv1 "github.com/krateoplatformops/core-provider/apis/compositiondefinitions/v1alpha1"
 	"github.com/krateoplatformops/unstructured-runtime/pkg/meta"
 
-	"github.com/krateoplatformops/chart-inspector/internal/getter"
 	"github.com/krateoplatformops/chart-inspector/internal/handlers"
 	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
 	"github.com/krateoplatformops/chart-inspector/internal/helper"
+	"github.com/krateoplatformops/chart-inspector/internal/inspector"
 	"github.com/krateoplatformops/chart-inspector/internal/tracer"
-	compositionMeta "github.com/krateoplatformops/composition-dynamic-controller/pkg/meta"
-	helmconfig "github.com/krateoplatformops/plumbing/helm"
-	helmutils "github.com/krateoplatformops/plumbing/helm/utils"
 	"github.com/krateoplatformops/plumbing/http/response"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/rest"
 )
 
 const (
 	AnnotationKeyReconciliationGracefullyPaused = "krateo.io/gracefully-paused"
 )
 
+// Values of the output query parameter.
+const (
+	outputList   = "list"
+	outputReport = "report"
+	outputHAR    = "har"
+)
+
+var outputs = []string{outputList, outputReport, outputHAR}
+
 type handler struct {
 	handlers.HandlerOptions
 }
@@ -54,169 +56,110 @@ var _ http.Handler = (*handler)(nil)
 // @Param compositionGroup query string false "Composition group" default(composition.krateo.io)
 // @Param compositionVersion query string true "Composition version"
 // @Param compositionResource query string true "Composition resource name"
+// @Param aggregate query bool false "Collapse duplicate entries, merging their verbs and counting the calls" default(false)
+// @Param origin query []string false "Only return entries of these origins" collectionFormat(csv) Enums(rendered, lookup, discovery, helm)
+// @Param output query string false "Response format: the plain list of resources, a report that also lists the lookups that found nothing, the forbidden calls, the API warnings and the admission rejections, or the full HTTP exchange with the API server as HAR 1.2" Enums(list, report, har) default(list)
+// @Param redact query bool false "With output=har, redact the data of Secrets. The Authorization, Cookie and Impersonate-* headers are always redacted" default(true)
+// @Param impersonate query string false "Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service"
+// @Param cassette query string false "Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server" Enums(record, replay)
+// @Param maxCalls query int false "Maximum number of API calls recorded, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param maxBodyBytes query int false "Maximum number of body bytes captured with output=har or cassette=record, 0 for no limit (default: the service limit). Recording a cassette fails when it is reached"
+// @Param merge query bool false "Union the traced resources with the objects of the rendered release manifest, marking where each entry was seen" default(false)
+// @Param timings query bool false "Time the phases of the request and the API calls of the dry-run: a timings object in the report (output=report) and a Server-Timing header" default(false)
 // @Produce json
-// @Success 200 {object} []Resource
+// @Success 200 {object} []Resource "The traced resources, or a resources.Report when output=report"
+// @Header 200,422 {string} X-Chart-Inspector-Truncated "Set to true when the tracer reached its limits and the result lacks some calls"
+// @Header 200,422 {string} Server-Timing "Durations of the phases of the request, with timings=true"
+// @Failure 422 {object} resources.Report "The dry-run failed, e.g. because admission rejected an object (output=report or output=har only), or recording the cassette reached maxCalls or maxBodyBytes"
 // @Router /resources [get]
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	compositionName := r.URL.Query().Get("compositionName")
-	compositionNamespace := r.URL.Query().Get("compositionNamespace")
-	compositionDefinitionName := r.URL.Query().Get("compositionDefinitionName")
-	compositionDefinitionNamespace := r.URL.Query().Get("compositionDefinitionNamespace")
-	compositionVersion := r.URL.Query().Get("compositionVersion")
-	compositionResource := r.URL.Query().Get("compositionResource")
-
-	compositionGroup := helper.GetQueryParamWithDefault(r, "compositionGroup", "composition.krateo.io")
-	compositionDefinitionGroup := helper.GetQueryParamWithDefault(r, "compositionDefinitionGroup", "core.krateo.io")
-	compositionDefinitionVersion := helper.GetQueryParamWithDefault(r, "compositionDefinitionVersion", "v1alpha1")
-	compositionDefinitionResource := helper.GetQueryParamWithDefault(r, "compositionDefinitionResource", "compositiondefinitions")
-
-	log := h.Log.With(slog.String(
-		"compositionName", compositionName),
-		slog.String("compositionNamespace", compositionNamespace),
-		slog.String("compositionDefinitionName", compositionDefinitionName),
-		slog.String("compositionDefinitionNamespace", compositionDefinitionNamespace))
-
-	if compositionName == "" || compositionNamespace == "" || compositionDefinitionName == "" || compositionDefinitionNamespace == "" || compositionVersion == "" || compositionResource == "" {
-		log.Error("missing required query parameters")
-		response.BadRequest(w, fmt.Errorf("missing required query parameters"))
+	aggregate := helper.GetQueryParamBool(r, "aggregate", false)
+	origins := helper.GetQueryParamList(r, "origin")
+	output := helper.GetQueryParamWithDefault(r, "output", outputList)
+	redact := helper.GetQueryParamBool(r, "redact", true)
+	timings := helper.GetQueryParamBool(r, "timings", false)
+	merge := helper.GetQueryParamBool(r, "merge", false)
+
+	req, err := readRequest(r, h.HandlerOptions)
+	log := req.Logger(h.Log)
+	if err != nil {
+		log.Error("invalid query parameters", slog.Any("err", err))
+		response.BadRequest(w, err)
 		return
 	}
 
-	k8scli := getter.NewClient(
-		h.DynamicClient,
-	)
-
-	compositionGVR := schema.GroupVersionResource{
-		Group:    compositionGroup,
-		Version:  compositionVersion,
-		Resource: compositionResource,
+	if !slices.Contains(outputs, output) {
+		log.Error("invalid output query parameter", slog.String("output", output))
+		response.BadRequest(w, fmt.Errorf("invalid output %q, must be one of %v", output, outputs))
+		return
 	}
-	compositionDefinitionGVR := schema.GroupVersionResource{
-		Group:    compositionDefinitionGroup,
-		Version:  compositionDefinitionVersion,
-		Resource: compositionDefinitionResource,
+
+	for _, origin := range origins {
+		if !slices.Contains(resources.Origins, origin) {
+			log.Error("invalid origin query parameter", slog.String("origin", origin))
+			response.BadRequest(w, fmt.Errorf("invalid origin %q, must be one of %v", origin, resources.Origins))
+			return
+		}
 	}
 
 	log.Info("Handling request to get resources")
 
-	composition, err := h.DynamicClient.
-		Resource(compositionGVR).
-		Namespace(compositionNamespace).
-		Get(context.Background(), compositionName, v1.GetOptions{})
+	req.Capture = output == outputHAR
+	ins, err := inspector.Run(context.Background(), h.HandlerOptions, req, log)
 	if err != nil {
-		log.Error("unable to get composition",
-			slog.String("compositionName", compositionName),
-			slog.String("compositionNamespace", compositionNamespace),
-			slog.String("compositionVersion", compositionVersion),
-			slog.String("compositionResource", compositionResource),
-			slog.String("compositionGroup", compositionGroup),
-			slog.Any("err", err),
-		)
-		response.InternalError(w, err)
+		response.Encode(w, response.New(inspector.StatusCode(err), err))
 		return
 	}
+	tr := ins.Tracer
+	installErr := ins.InstallErr
 
-	// NOTE: bValues are extracted and injected with composition context
-	bValuesMap, err := helmutils.ValuesFromSpec(composition)
-	if err != nil {
-		log.Error("unable to extract values from composition",
-			slog.Any("err", err),
-		)
-		response.InternalError(w, err)
+	// The report and the HAR explain a failed dry-run (e.g. an admission
+	// rejection), the plain list cannot.
+	if installErr != nil && output == outputList {
+		response.InternalError(w, installErr)
 		return
 	}
-	err = bValuesMap.InjectGlobalValues(composition, h.Plurarizer, h.KrateoNamespace)
-	if err != nil {
-		log.Error("unable to inject global values",
-			slog.Any("err", err),
-		)
-		response.InternalError(w, err)
+	// Only a merge depends on the manifest.
+	if merge && ins.ManifestErr != nil {
+		response.InternalError(w, ins.ManifestErr)
 		return
 	}
 
-	tracer := &tracer.Tracer{}
-	// Create a wrapped REST config with the tracer RoundTripper for this request
-	wrappedCfg := rest.CopyConfig(h.RestConfig)
-	wrappedCfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
-		return tracer.WithRoundTripper(rt)
+	resLi := ins.Resources
+	if merge {
+		resLi = inspector.Merge(resLi, ins.Rendered)
 	}
-
-	compositionDefinitionU, err := h.DynamicClient.
-		Resource(compositionDefinitionGVR).
-		Namespace(compositionDefinitionNamespace).
-		Get(context.Background(), compositionDefinitionName, v1.GetOptions{})
-	if err != nil {
-		log.Error("unable to get composition definition",
-			slog.Any("err", err),
-		)
-		response.InternalError(w, err)
-		return
-	}
-	var compositionDefinition coreprovv1.CompositionDefinition
-	err = runtime.DefaultUnstructuredConverter.FromUnstructured(compositionDefinitionU.Object, &compositionDefinition)
-	if err != nil {
-		log.Error("unable to convert composition definition",
-			slog.Any("err", err),
-		)
-		response.InternalError(w, err)
-		return
+	if len(origins) > 0 {
+		resLi = filterByOrigin(resLi, origins)
 	}
 
-	// Install the Helm chart with DryRun to capture templated resources
-	// Set namespace to composition's namespace for proper resource isolation
-	installCfg := &helmconfig.InstallConfig{
-		ActionConfig: &helmconfig.ActionConfig{
-			ChartVersion:          compositionDefinition.Spec.Chart.Version,
-			ChartName:             compositionDefinition.Spec.Chart.Repo,
-			Values:                bValuesMap,
-			Username:              "",
-			Password:              "",
-			InsecureSkipTLSverify: compositionDefinition.Spec.Chart.InsecureSkipVerifyTLS,
-			DryRun:                helmconfig.DryRunServer,
-			IncludeCRDs:           true,
-			SkipCRDs:              false,
-		},
-		Namespace:       compositionNamespace, // Override namespace for this composition
-		CreateNamespace: true,
-		RestConfig:      wrappedCfg, // Pass wrapped config with tracer integration
-	}
-
-	// Retrieve credentials from secret if specified
-	if compositionDefinition.Spec.Chart != nil && compositionDefinition.Spec.Chart.Credentials != nil {
-		installCfg.ActionConfig.Username = compositionDefinition.Spec.Chart.Credentials.Username
-
-		// Retrieve password from secret
-		passwd, err := k8scli.GetSecret(compositionDefinition.Spec.Chart.Credentials.PasswordRef)
-		if err != nil {
-			log.Error("unable to get secret",
-				slog.Any("err", err),
-			)
-			response.InternalError(w, err)
-			return
+	var body any
+	switch output {
+	case outputHAR:
+		body = tr.HAR(redact)
+	case outputReport:
+		report := newReport(resLi, aggregate)
+		if installErr != nil {
+			report.Error = installErr.Error()
 		}
-		installCfg.ActionConfig.Password = passwd
-	}
-
-	// Install with DryRun to get templated manifest using global helm client with tracer integration
-	_, err = h.HelmClient.Install(context.Background(), compositionMeta.GetReleaseName(composition), compositionDefinition.Spec.Chart.Url, installCfg)
-	if err != nil {
-		log.Error("unable to template chart",
-			slog.Any("err", err),
-		)
-		response.InternalError(w, err)
-		return
-	}
-
-	// Getting the resources
-	resLi := tracer.GetResources()
-
-	// Ensure resLi is not nil to avoid null in JSON response
-	if resLi == nil {
-		resLi = []resources.Resource{}
+		report.Truncated = tr.Truncated()
+		if timings {
+			report.Timings = newTimings(ins.Phases, tr.GetCalls())
+		}
+		body = report
+	default:
+		if aggregate {
+			resLi = tracer.Aggregate(resLi)
+		}
+		// Ensure resLi is not nil to avoid null in JSON response
+		if resLi == nil {
+			resLi = []resources.Resource{}
+		}
+		body = resLi
 	}
 
-	if meta.IsVerbose(composition) {
-		b, err := json.Marshal(resLi)
+	if meta.IsVerbose(ins.Composition) {
+		b, err := json.Marshal(body)
 		if err != nil {
 			log.Error("unable to marshal resources for logging",
 				slog.Any("err", err),
@@ -230,8 +173,21 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// write the response in JSON format
 	w.Header().Set("Content-Type", "application/json")
+	if timings {
+		w.Header().Set("Server-Timing", serverTiming(ins.Phases))
+	}
+	if tr.Truncated() {
+		log.Warn("the tracer reached its limits, the result is truncated",
+			slog.Int("maxCalls", req.Limits.MaxCalls),
+			slog.Int("maxBodyBytes", req.Limits.MaxBodyBytes),
+		)
+		w.Header().Set(inspector.HeaderTruncated, "true")
+	}
+	if installErr != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
 	enc := json.NewEncoder(w)
-	err = enc.Encode(resLi)
+	err = enc.Encode(body)
 	if err != nil {
 		log.Error("unable to marshal resources",
 			slog.Any("err", err),
@@ -240,5 +196,93 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if installErr != nil {
+		log.Info("Reported failed dry-run")
+		return
+	}
+
 	log.Info("Successfully handled request to get resources")
 }
+
+// readRequest reads the inspection out of the query parameters of a GET and,
+// for a POST, out of the objects in its body as well.
+//
+// @Summary Get the Helm chart resources of inline objects
+// @Description Get the Helm chart resources of a Composition and a CompositionDefinition posted in the body, which need not exist in the cluster. The query parameters are those of the GET; the composition ones identify the Composition built out of values when the body has no composition
+// @ID post-chart-resources
+// @Param compositionName query string false "Composition name, when the body has no composition"
+// @Param compositionNamespace query string false "Composition namespace, when the body has no composition"
+// @Param compositionGroup query string false "Composition group, when the body has no composition" default(composition.krateo.io)
+// @Param compositionVersion query string false "Composition version, when the body has no composition"
+// @Param compositionResource query string false "Composition resource name (default: the pluralized kind of the composition in the body)"
+// @Param aggregate query bool false "Collapse duplicate entries, merging their verbs and counting the calls" default(false)
+// @Param origin query []string false "Only return entries of these origins" collectionFormat(csv) Enums(rendered, lookup, discovery, helm)
+// @Param output query string false "Response format, as for the GET" Enums(list, report, har) default(list)
+// @Param redact query bool false "With output=har, redact the Authorization headers and the data of Secrets" default(true)
+// @Param impersonate query string false "Make the dry-run as this ServiceAccount, given as namespace/name, instead of as the service"
+// @Param cassette query string false "Record the dry-run to the cassette directory of the service, or replay it from there instead of calling the API server" Enums(record, replay)
+// @Param maxCalls query int false "Maximum number of API calls recorded, 0 for no limit (default: the service limit)"
+// @Param maxBodyBytes query int false "Maximum number of body bytes captured with output=har or cassette=record, 0 for no limit (default: the service limit)"
+// @Param merge query bool false "Union the traced resources with the objects of the rendered release manifest, marking where each entry was seen" default(false)
+// @Param timings query bool false "Time the phases of the request and the API calls of the dry-run: a timings object in the report (output=report) and a Server-Timing header" default(false)
+// @Param objects body inspector.Inline true "The composition or the values of the chart, and the composition definition or the chart reference"
+// @Accept json
+// @Produce json
+// @Success 200 {object} []Resource "The traced resources, or a resources.Report when output=report"
+// @Header 200,422 {string} X-Chart-Inspector-Truncated "Set to true when the tracer reached its limits and the result lacks some calls"
+// @Header 200,422 {string} Server-Timing "Durations of the phases of the request, with timings=true"
+// @Failure 422 {object} resources.Report "The dry-run failed, e.g. because admission rejected an object (output=report or output=har only)"
+// @Router /resources [post]
+func readRequest(r *http.Request, opts handlers.HandlerOptions) (inspector.Request, error) {
+	if r.Method == http.MethodPost {
+		return inspector.FromBody(r, opts)
+	}
+	return inspector.FromQuery(r, opts)
+}
+
+func filterByOrigin(li []resources.Resource, origins []string) []resources.Resource {
+	res := []resources.Resource{}
+	for _, el := range li {
+		if slices.Contains(origins, el.Origin) {
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+// newReport builds the detailed response out of the traced resources li. When
+// aggregate is set every section is aggregated on its own, so that a lookup
+// that first failed and then succeeded is still reported as not found.
+func newReport(li []resources.Resource, aggregate bool) resources.Report {
+	report := resources.Report{
+		Resources: []resources.Resource{},
+		NotFound:  []resources.Resource{},
+		Forbidden: []resources.Resource{},
+		Warnings:  []resources.Resource{},
+		Admission: []resources.Resource{},
+	}
+	for _, el := range li {
+		report.Resources = append(report.Resources, el)
+		switch {
+		case el.Status == http.StatusNotFound && el.Origin == resources.OriginLookup:
+			report.NotFound = append(report.NotFound, el)
+		case el.Admission != "":
+			report.Admission = append(report.Admission, el)
+		case el.Status == http.StatusForbidden:
+			report.Forbidden = append(report.Forbidden, el)
+		}
+		if len(el.Warnings) > 0 {
+			report.Warnings = append(report.Warnings, el)
+		}
+	}
+
+	if aggregate {
+		report.Resources = tracer.Aggregate(report.Resources)
+		report.NotFound = tracer.Aggregate(report.NotFound)
+		report.Forbidden = tracer.Aggregate(report.Forbidden)
+		report.Warnings = tracer.Aggregate(report.Warnings)
+		report.Admission = tracer.Aggregate(report.Admission)
+	}
+
+	return report
+}
@@ -4,6 +4,7 @@
 package resources
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -14,11 +15,15 @@ import (
 
 	"github.com/gobuffalo/flect"
 	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	inspected "github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
 	"gotest.tools/v3/assert"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 
 	"context"
 	"os"
@@ -98,7 +103,7 @@ func TestResourcesHandler(t *testing.T) {
 			compositionDefinition: "focus.yaml",
 			composition:           "focus.yaml",
 			expectedStatus:        http.StatusOK,
-			expectedBody:          `[{"group":"finops.krateo.io","version":"v1alpha1","resource":"datapresentationazures","name":"focus-1-focus-data-presentation-azure","namespace":"krateo-system"},{"group":"finops.krateo.io","version":"v1alpha1","resource":"datapresentationazures","name":"focus-1-focus-data-presentation-azure","namespace":"krateo-system"}]`,
+			expectedBody:          `[{"group":"","version":"v1","resource":"secrets","name":"","namespace":"krateo-system","verbs":["list"],"kind":"Secret","namespaced":true,"origin":"helm","status":200},{"group":"finops.krateo.io","version":"v1alpha1","resource":"datapresentationazures","name":"focus-1-focus-data-presentation-azure","namespace":"krateo-system","verbs":["get"],"kind":"DataPresentationAzure","namespaced":true,"origin":"lookup","status":200},{"group":"finops.krateo.io","version":"v1alpha1","resource":"datapresentationazures","name":"focus-1-focus-data-presentation-azure","namespace":"krateo-system","verbs":["get"],"kind":"DataPresentationAzure","namespaced":true,"origin":"lookup","status":200}]`,
 		},
 	}
 
@@ -143,6 +148,7 @@ func TestResourcesHandler(t *testing.T) {
 			r.WithNamespace(namespace)
 
 			dynamic := dynamic.NewForConfigOrDie(copyCfg)
+			mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discovery.NewDiscoveryClientForConfigOrDie(copyCfg)))
 
 			for _, tt := range tests {
 				t.Run(tt.name, func(t *testing.T) {
@@ -191,6 +197,7 @@ func TestResourcesHandler(t *testing.T) {
 						DynamicClient:   dynamic,
 						KrateoNamespace: "test-system",
 						Plurarizer:      &mockPluralizer{},
+						RESTMapper:      mapper,
 						RestConfig:      cfg,
 						HelmClient:      helmClient,
 					})
@@ -206,8 +213,23 @@ func TestResourcesHandler(t *testing.T) {
 					}
 
 					if len(tt.expectedBody) > 0 {
-						respBody := strings.TrimSpace(rec.Body.String())
-						assert.Equal(t, tt.expectedBody, respBody, "unexpected response body")
+						got := []inspected.Resource{}
+						if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+							t.Fatalf("unable to decode response body: %v", err)
+						}
+						// The template paths depend on the chart layout: only
+						// check that the rendered objects carry one.
+						for i, el := range got {
+							if el.Resource == "datapresentationazures" && !strings.HasPrefix(el.Template, "templates/") {
+								t.Errorf("expected a template for %s, got %q", el.Name, el.Template)
+							}
+							got[i].Source, got[i].Template, got[i].Subchart = "", "", ""
+						}
+						respBody, err := json.Marshal(got)
+						if err != nil {
+							t.Fatal(err)
+						}
+						assert.Equal(t, tt.expectedBody, string(respBody), "unexpected response body")
 					}
 				})
 			}
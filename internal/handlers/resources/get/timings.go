@@ -0,0 +1,44 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// newTimings builds the timings of a request out of its phases and of the API
+// calls of its dry-run.
+func newTimings(phases []resources.Phase, calls []resources.Call) *resources.Timings {
+	api := []resources.APITiming{}
+	for _, origin := range resources.Origins {
+		timing := resources.APITiming{Origin: origin}
+		for _, call := range calls {
+			if call.Origin == origin {
+				timing.Count++
+				timing.DurationMs += call.DurationMs
+			}
+		}
+		if timing.Count > 0 {
+			api = append(api, timing)
+		}
+	}
+
+	if calls == nil {
+		calls = []resources.Call{}
+	}
+	return &resources.Timings{
+		Phases: phases,
+		API:    api,
+		Calls:  calls,
+	}
+}
+
+// serverTiming formats phases as a Server-Timing header value.
+func serverTiming(phases []resources.Phase) string {
+	metrics := make([]string, 0, len(phases))
+	for _, phase := range phases {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", phase.Name, phase.DurationMs))
+	}
+	return strings.Join(metrics, ", ")
+}
@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+func TestNewTimings(t *testing.T) {
+	calls := []resources.Call{
+		{URI: "/apis", Origin: resources.OriginDiscovery, DurationMs: 2},
+		{URI: "/api/v1/namespaces/demo/secrets", Origin: resources.OriginHelm, DurationMs: 3},
+		{URI: "/apis/apps/v1/namespaces/demo/deployments/web", Origin: resources.OriginLookup, DurationMs: 4},
+		{URI: "/api", Origin: resources.OriginDiscovery, DurationMs: 1.5},
+	}
+	phases := []resources.Phase{{Name: "install", DurationMs: 20}, {Name: "total", DurationMs: 25}}
+
+	got := newTimings(phases, calls)
+	expected := []resources.APITiming{
+		{Origin: resources.OriginLookup, Count: 1, DurationMs: 4},
+		{Origin: resources.OriginDiscovery, Count: 2, DurationMs: 3.5},
+		{Origin: resources.OriginHelm, Count: 1, DurationMs: 3},
+	}
+	if !reflect.DeepEqual(got.API, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got.API)
+	}
+	if !reflect.DeepEqual(got.Phases, phases) || !reflect.DeepEqual(got.Calls, calls) {
+		t.Errorf("expected the phases and calls to be kept, got %+v", got)
+	}
+
+	empty := newTimings(phases, nil)
+	if empty.Calls == nil || empty.API == nil {
+		t.Errorf("expected non-nil slices, got %+v", empty)
+	}
+}
+
+func TestServerTiming(t *testing.T) {
+	phases := []resources.Phase{{Name: "composition", DurationMs: 1.25}, {Name: "total", DurationMs: 30}}
+	if got, expected := serverTiming(phases), "composition;dur=1.250, total;dur=30.000"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
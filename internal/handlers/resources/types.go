@@ -1,9 +1,142 @@
 package resources
 
+// Origin categories of a traced call.
+const (
+	// OriginRendered marks a write to an object the chart renders and owns.
+	OriginRendered = "rendered"
+	// OriginLookup marks a read-only call, such as a template lookup.
+	OriginLookup = "lookup"
+	// OriginDiscovery marks a call that inspects the API itself, such as
+	// reading CustomResourceDefinitions or APIServices.
+	OriginDiscovery = "discovery"
+	// OriginHelm marks Helm's own bookkeeping: the release storage
+	// (sh.helm.release.v1 Secrets or ConfigMaps) and the release Namespace.
+	OriginHelm = "helm"
+)
+
+// Origins lists all the origin categories.
+var Origins = []string{OriginRendered, OriginLookup, OriginDiscovery, OriginHelm}
+
+// Where an entry was seen, when traced calls are merged with the release
+// manifest.
+const (
+	// SeenTraffic marks an entry only seen in the traffic of the dry-run.
+	SeenTraffic = "traffic"
+	// SeenManifest marks an object of the release manifest the dry-run never
+	// touched.
+	SeenManifest = "manifest"
+	// SeenBoth marks an object of the release manifest the dry-run touched.
+	SeenBoth = "both"
+)
+
 type Resource struct {
-	Group     string `json:"group"`
-	Version   string `json:"version"`
-	Resource  string `json:"resource"`
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
+	Group       string   `json:"group"`
+	Version     string   `json:"version"`
+	Resource    string   `json:"resource"`
+	Name        string   `json:"name"`
+	Namespace   string   `json:"namespace"`
+	Verbs       []string `json:"verbs,omitempty"`
+	Subresource string   `json:"subresource,omitempty"`
+	// Kind is the kind of the object, as resolved through discovery or, for
+	// a resource unknown to discovery, as carried in the body of the call
+	// (e.g. the manifest sent by a dry-run create).
+	Kind string `json:"kind,omitempty"`
+	// Namespaced tells whether the resource is namespaced or cluster-scoped.
+	// It is unset when the resource is unknown to the API server.
+	Namespaced *bool `json:"namespaced,omitempty"`
+	// Origin is the category of the call, one of Origins.
+	Origin string `json:"origin,omitempty"`
+	// Status is the HTTP status code the API server answered the call with,
+	// or zero when the call failed before a response was received.
+	Status int `json:"status,omitempty"`
+	// Warnings are the Warning headers the API server answered the call with:
+	// deprecation notices and warnings from admission webhooks and policies.
+	Warnings []string `json:"warnings,omitempty"`
+	// Admission is the message of the rejection of a write by admission
+	// (webhooks, ValidatingAdmissionPolicies, built-in admission plugins).
+	Admission string `json:"admission,omitempty"`
+	// Seen tells whether the entry was seen in the traffic of the dry-run, in
+	// the release manifest or in both. It is only set when they are merged.
+	Seen string `json:"seen,omitempty"`
+	// Source is the path of the template that rendered the object, as found
+	// in the release manifest.
+	Source string `json:"source,omitempty"`
+	// Template is the path of the template that rendered the object within
+	// its chart, e.g. "templates/service.yaml".
+	Template string `json:"template,omitempty"`
+	// Subchart is the subchart the template belongs to, empty for a template
+	// of the chart itself. Nested subcharts are joined with a slash.
+	Subchart string `json:"subchart,omitempty"`
+	// Count is the number of traced calls merged into this entry. It is only
+	// set on aggregated results.
+	Count int `json:"count,omitempty"`
+}
+
+// Report is the detailed /resources response. Besides the traced resources it
+// lists the calls that did not succeed and that are worth a chart author's
+// attention.
+type Report struct {
+	Resources []Resource `json:"resources"`
+	// NotFound lists the lookups answered with 404 Not Found, e.g. a template
+	// lookup that returned an empty object.
+	NotFound []Resource `json:"notFound"`
+	// Forbidden lists the calls answered with 403 Forbidden: the identity
+	// running the dry-run is not allowed to see or change them.
+	Forbidden []Resource `json:"forbidden"`
+	// Warnings lists the calls the API server answered with warnings.
+	Warnings []Resource `json:"warnings"`
+	// Admission lists the writes rejected by admission: the Composition
+	// would be rejected when the CDC applies it.
+	Admission []Resource `json:"admission"`
+	// Error is the reason the dry-run failed, if it did. The report then
+	// covers the calls made until the failure.
+	Error string `json:"error,omitempty"`
+	// Truncated is set when the tracer reached its limits: the report then
+	// lacks the calls made past them.
+	Truncated bool `json:"truncated,omitempty"`
+	// Timings breaks down the time spent serving the request. It is only set
+	// when requested.
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// Call is the timing of a single API call made during the dry-run, discovery
+// calls included.
+type Call struct {
+	Method string `json:"method"`
+	// URI is the path and query of the call.
+	URI string `json:"uri"`
+	// Verb is the RBAC verb of the call, empty for a discovery call that does
+	// not target a resource.
+	Verb   string `json:"verb,omitempty"`
+	Origin string `json:"origin"`
+	Status int    `json:"status,omitempty"`
+	Start  string `json:"start"`
+	// DurationMs is the time the API server took to answer the call, in
+	// milliseconds.
+	DurationMs float64 `json:"durationMs"`
+}
+
+// Phase is the duration of a phase of the handling of a request.
+type Phase struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// APITiming sums the durations of the API calls of an origin.
+type APITiming struct {
+	Origin     string  `json:"origin"`
+	Count      int     `json:"count"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// Timings breaks down the time spent serving a /resources request.
+type Timings struct {
+	// Phases are the phases of the request, in order, ending with the total.
+	Phases []Phase `json:"phases"`
+	// API sums the API calls of the dry-run by origin. The install phase
+	// minus their total is the time spent downloading and rendering the
+	// chart.
+	API []APITiming `json:"api"`
+	// Calls lists every API call of the dry-run.
+	Calls []Call `json:"calls"`
 }
@@ -0,0 +1,93 @@
+package values
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/inspector"
+	"github.com/krateoplatformops/chart-inspector/internal/values"
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+type handler struct {
+	handlers.HandlerOptions
+}
+
+func GetValues(opts handlers.HandlerOptions) http.Handler {
+	return &handler{
+		HandlerOptions: opts,
+	}
+}
+
+var _ http.Handler = (*handler)(nil)
+
+// dryRunParams are the query parameters of the endpoints making a dry-run.
+// /values makes none, so it refuses them rather than ignore them.
+var dryRunParams = []string{"impersonate", "cassette", "maxCalls", "maxBodyBytes"}
+
+// @Summary Get the values of the Helm chart of a composition
+// @Description Get the values the chart of a composition is rendered with: its spec, with the global values the composition-dynamic-controller injects, coalesced with the chart defaults. Each value is annotated with its source, and those that look sensitive are redacted. The chart is not installed, so the dry-run parameters of the other endpoints (impersonate, cassette, maxCalls, maxBodyBytes) are refused with 400
+// @ID get-chart-values
+// @Param compositionName query string true "Composition name"
+// @Param compositionNamespace query string true "Composition namespace"
+// @Param compositionDefinitionName query string true "Composition definition name"
+// @Param compositionDefinitionNamespace query string true "Composition definition namespace"
+// @Param compositionDefinitionGroup query string false "Composition definition group" default(core.krateo.io)
+// @Param compositionDefinitionVersion query string false "Composition definition version" default(v1alpha1)
+// @Param compositionDefinitionResource query string false "Composition definition resource name" default(compositiondefinitions)
+// @Param compositionGroup query string false "Composition group" default(composition.krateo.io)
+// @Param compositionVersion query string true "Composition version"
+// @Param compositionResource query string true "Composition resource name"
+// @Produce json
+// @Success 200 {object} values.Annotated "The values, with the source of each of them"
+// @Router /values [get]
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.MethodNotAllowed(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	for _, name := range dryRunParams {
+		if r.URL.Query().Has(name) {
+			err := fmt.Errorf("unsupported query parameter %s: /values makes no dry-run", name)
+			h.Log.Error("invalid query parameters", slog.Any("err", err))
+			response.BadRequest(w, err)
+			return
+		}
+	}
+
+	req, err := inspector.FromQuery(r, h.HandlerOptions)
+	log := req.Logger(h.Log)
+	if err != nil {
+		log.Error("invalid query parameters", slog.Any("err", err))
+		response.BadRequest(w, err)
+		return
+	}
+
+	log.Info("Handling request to get values")
+
+	vals, err := inspector.Values(context.Background(), h.HandlerOptions, req, log)
+	if err != nil {
+		response.InternalError(w, err)
+		return
+	}
+
+	res := values.Annotate(vals.Values, vals.Spec, vals.Global)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error("unable to marshal values",
+			slog.Any("err", err),
+		)
+		response.InternalError(w, err)
+		return
+	}
+
+	log.Info("Successfully handled request to get values",
+		slog.Int("redacted", len(res.Redacted)),
+	)
+}
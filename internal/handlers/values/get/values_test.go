@@ -0,0 +1,32 @@
+package values
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/replaytest"
+)
+
+func TestValuesHandlerDryRunParams(t *testing.T) {
+	opts := handlers.HandlerOptions{Log: slog.New(slog.DiscardHandler)}
+
+	for _, query := range []string{"impersonate=demo-system/cdc", "cassette=record", "maxCalls=10", "maxBodyBytes=0"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/values?"+replaytest.Query+"&"+query, nil)
+			rec := httptest.NewRecorder()
+			GetValues(opts).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+			name, _, _ := strings.Cut(query, "=")
+			if !strings.Contains(rec.Body.String(), "unsupported query parameter "+name) {
+				t.Errorf("expected the error to name %s, got %s", name, rec.Body.String())
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package helper
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 func GetQueryParamWithDefault(r *http.Request, key, defaultValue string) string {
@@ -11,3 +13,39 @@ func GetQueryParamWithDefault(r *http.Request, key, defaultValue string) string
 	}
 	return value
 }
+
+// GetQueryParamBool parses the boolean query parameter key, returning
+// defaultValue when it is missing or not a valid boolean.
+func GetQueryParamBool(r *http.Request, key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(r.URL.Query().Get(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetQueryParamList returns the values of the query parameter key, accepting
+// both repeated parameters and comma-separated lists. Empty items are
+// dropped.
+func GetQueryParamList(r *http.Request, key string) []string {
+	var res []string
+	for _, value := range r.URL.Query()[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+	}
+	return res
+}
+
+// GetQueryParamInt parses the integer query parameter key, returning
+// defaultValue when it is missing and an error when it is not a valid
+// integer.
+func GetQueryParamInt(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
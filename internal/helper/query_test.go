@@ -3,6 +3,7 @@ package helper
 import (
 	"net/http"
 	"net/url"
+	"reflect"
 	"testing"
 )
 
@@ -73,3 +74,91 @@ func TestGetQueryParamWithDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestGetQueryParamBool(t *testing.T) {
+	tests := []struct {
+		name         string
+		rawQuery     string
+		defaultValue bool
+		expected     bool
+	}{
+		{name: "true", rawQuery: "flag=true", defaultValue: false, expected: true},
+		{name: "one", rawQuery: "flag=1", defaultValue: false, expected: true},
+		{name: "false", rawQuery: "flag=false", defaultValue: true, expected: false},
+		{name: "missing", rawQuery: "", defaultValue: true, expected: true},
+		{name: "empty", rawQuery: "flag=", defaultValue: false, expected: false},
+		{name: "invalid", rawQuery: "flag=yes", defaultValue: false, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{
+				URL: &url.URL{RawQuery: tt.rawQuery},
+			}
+
+			result := GetQueryParamBool(req, "flag", tt.defaultValue)
+			if result != tt.expected {
+				t.Errorf("GetQueryParamBool() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetQueryParamList(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		expected []string
+	}{
+		{name: "missing", rawQuery: "", expected: nil},
+		{name: "single", rawQuery: "item=a", expected: []string{"a"}},
+		{name: "comma separated", rawQuery: "item=a,b", expected: []string{"a", "b"}},
+		{name: "repeated", rawQuery: "item=a&item=b,c", expected: []string{"a", "b", "c"}},
+		{name: "blanks", rawQuery: "item=a,,%20b%20,", expected: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{
+				URL: &url.URL{RawQuery: tt.rawQuery},
+			}
+
+			result := GetQueryParamList(req, "item")
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("GetQueryParamList() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetQueryParamInt(t *testing.T) {
+	tests := []struct {
+		name         string
+		rawQuery     string
+		defaultValue int
+		expected     int
+		expectErr    bool
+	}{
+		{name: "value", rawQuery: "max=100", defaultValue: 10, expected: 100},
+		{name: "zero", rawQuery: "max=0", defaultValue: 10, expected: 0},
+		{name: "missing", rawQuery: "", defaultValue: 10, expected: 10},
+		{name: "empty", rawQuery: "max=", defaultValue: 10, expected: 10},
+		{name: "invalid", rawQuery: "max=ten", defaultValue: 10, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{
+				URL: &url.URL{RawQuery: tt.rawQuery},
+			}
+
+			result, err := GetQueryParamInt(req, "max", tt.defaultValue)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("GetQueryParamInt() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && result != tt.expected {
+				t.Errorf("GetQueryParamInt() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
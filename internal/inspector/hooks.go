@@ -0,0 +1,99 @@
+package inspector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	coreprovv1 "github.com/krateoplatformops/core-provider/apis/compositiondefinitions/v1alpha1"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	helmgetter "github.com/krateoplatformops/plumbing/helm/getter"
+	helmv3 "github.com/krateoplatformops/plumbing/helm/v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/client-go/rest"
+)
+
+// Hook is a hook of a chart, rendered as on its install.
+type Hook struct {
+	// Path is the template of the hook, e.g. mychart/templates/job.yaml.
+	Path string
+	// Manifest is the rendered hook, a single YAML document.
+	Manifest string
+}
+
+// renderHooks returns the hooks and the NOTES.txt chrt renders when installed
+// as releaseName in namespace with vals. The release the Helm client of the
+// service returns has neither, so the chart is installed again with a
+// server-side dry-run of the Helm SDK, calling the API server with cfg.
+func renderHooks(ctx context.Context, cfg *rest.Config, chrt *chart.Chart, releaseName, namespace string, vals map[string]any) ([]Hook, string, error) {
+	actionConfig := new(action.Configuration)
+	getter := helmv3.NewRESTClientGetter(namespace, nil, cfg)
+	if err := actionConfig.Init(getter, namespace, os.Getenv("HELM_DRIVER"), func(string, ...any) {}); err != nil {
+		return nil, "", fmt.Errorf("failed to init action config: %w", err)
+	}
+
+	// As the Helm client of the service installs the chart for Run.
+	install := action.NewInstall(actionConfig)
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+	install.CreateNamespace = true
+	install.IncludeCRDs = true
+	install.DisableOpenAPIValidation = true
+	install.DryRun = true
+	install.DryRunOption = "server"
+
+	rel, err := install.RunWithContext(ctx, chrt, vals)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hooks := make([]Hook, 0, len(rel.Hooks))
+	for _, h := range rel.Hooks {
+		hooks = append(hooks, Hook{Path: h.Path, Manifest: h.Manifest})
+	}
+	var notes string
+	if rel.Info != nil {
+		notes = rel.Info.Notes
+	}
+	return hooks, notes, nil
+}
+
+// pullChart pulls the chart of chartInfo, or reads it from the chart cache of
+// opts, with the credentials of chartInfo when it has some.
+func pullChart(ctx context.Context, opts handlers.HandlerOptions, chartInfo *coreprovv1.ChartInfo, username, password string, log *slog.Logger) (*chart.Chart, error) {
+	getterOpts := []helmgetter.Option{
+		helmgetter.WithVersion(chartInfo.Version),
+		helmgetter.WithRepo(chartInfo.Repo),
+		helmgetter.WithCache(opts.ChartCache),
+		helmgetter.WithInsecureSkipVerifyTLS(chartInfo.InsecureSkipVerifyTLS),
+	}
+	if chartInfo.Credentials != nil {
+		getterOpts = append(getterOpts, helmgetter.WithCredentials(username, password))
+	}
+
+	chartReader, _, err := helmgetter.Get(ctx, chartInfo.Url, getterOpts...)
+	if err != nil {
+		log.Error("unable to get chart",
+			slog.String("url", chartInfo.Url),
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+	if c, ok := chartReader.(io.Closer); ok {
+		defer c.Close()
+	}
+	chrt, err := loader.LoadArchive(chartReader)
+	if err != nil {
+		log.Error("unable to load chart",
+			slog.String("url", chartInfo.Url),
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+	return chrt, nil
+}
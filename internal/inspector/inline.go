@@ -0,0 +1,176 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gobuffalo/flect"
+	compositionMeta "github.com/krateoplatformops/composition-dynamic-controller/pkg/meta"
+	coreprovv1 "github.com/krateoplatformops/core-provider/apis/compositiondefinitions/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/helper"
+)
+
+// maxInlineBytes bounds the size of the objects posted inline.
+const maxInlineBytes = 4 << 20
+
+// Inline is the body of a request inspecting objects that need not exist in
+// the cluster: a Composition, or just the values of its chart, and a
+// CompositionDefinition, or just the reference to its chart.
+type Inline struct {
+	// Composition is the Composition to inspect. Without it, one is built
+	// with Values as its spec and the composition query parameters as its
+	// identity.
+	Composition map[string]any `json:"composition,omitempty"`
+	// Values are the values of the chart, when Composition is not set.
+	Values map[string]any `json:"values,omitempty"`
+	// CompositionDefinition is the CompositionDefinition whose chart is
+	// installed. Without it, one is built out of Chart.
+	CompositionDefinition map[string]any `json:"compositionDefinition,omitempty"`
+	// Chart is the chart to install, when CompositionDefinition is not set.
+	Chart *coreprovv1.ChartInfo `json:"chart,omitempty"`
+}
+
+// FromBody reads the request out of the Inline body of r and its query
+// parameters, the defaults coming from opts. The query parameters identify
+// the objects the body leaves out. The returned Request holds the parameters
+// read so far when the error is not nil.
+func FromBody(r *http.Request, opts handlers.HandlerOptions) (Request, error) {
+	req := Request{
+		CompositionGVR: schema.GroupVersionResource{
+			Group:    helper.GetQueryParamWithDefault(r, "compositionGroup", "composition.krateo.io"),
+			Version:  r.URL.Query().Get("compositionVersion"),
+			Resource: r.URL.Query().Get("compositionResource"),
+		},
+		CompositionName:                r.URL.Query().Get("compositionName"),
+		CompositionNamespace:           r.URL.Query().Get("compositionNamespace"),
+		CompositionDefinitionName:      r.URL.Query().Get("compositionDefinitionName"),
+		CompositionDefinitionNamespace: r.URL.Query().Get("compositionDefinitionNamespace"),
+	}
+
+	body := Inline{}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxInlineBytes)).Decode(&body); err != nil {
+		return req, fmt.Errorf("unable to decode the inline objects: %w", err)
+	}
+
+	switch {
+	case body.Composition != nil && body.Values != nil:
+		return req, fmt.Errorf("invalid body: composition and values are mutually exclusive")
+	case body.Composition != nil:
+		composition := &unstructured.Unstructured{Object: body.Composition}
+		if composition.GetAPIVersion() == "" || composition.GetKind() == "" || composition.GetName() == "" || composition.GetNamespace() == "" {
+			return req, fmt.Errorf("invalid composition: apiVersion, kind, metadata.name and metadata.namespace are required")
+		}
+		if err := validateComposition(composition.GetName(), composition.GetNamespace()); err != nil {
+			return req, fmt.Errorf("invalid composition: %w", err)
+		}
+		gv, err := schema.ParseGroupVersion(composition.GetAPIVersion())
+		if err != nil {
+			return req, fmt.Errorf("invalid composition: %w", err)
+		}
+		if req.CompositionGVR.Resource == "" {
+			req.CompositionGVR.Resource = flect.Pluralize(strings.ToLower(composition.GetKind()))
+		}
+		req.CompositionGVR.Group, req.CompositionGVR.Version = gv.Group, gv.Version
+		req.CompositionName, req.CompositionNamespace = composition.GetName(), composition.GetNamespace()
+		req.Composition = composition
+	default:
+		if req.CompositionName == "" || req.CompositionNamespace == "" || req.CompositionGVR.Version == "" || req.CompositionGVR.Resource == "" {
+			return req, fmt.Errorf("missing required query parameters: the composition is not in the body")
+		}
+		req.Composition = newComposition(req.CompositionGVR, kindOf(opts, req.CompositionGVR), req.CompositionName, req.CompositionNamespace, body.Values)
+	}
+	if compositionMeta.GetReleaseName(req.Composition) == "" {
+		// As the composition-dynamic-controller does on its first reconcile.
+		compositionMeta.SetReleaseName(req.Composition, req.Composition.GetName())
+	}
+
+	switch {
+	case body.CompositionDefinition != nil && body.Chart != nil:
+		return req, fmt.Errorf("invalid body: compositionDefinition and chart are mutually exclusive")
+	case body.CompositionDefinition != nil:
+		cd := &unstructured.Unstructured{Object: body.CompositionDefinition}
+		if url, _, _ := unstructured.NestedString(cd.Object, "spec", "chart", "url"); url == "" {
+			return req, fmt.Errorf("invalid compositionDefinition: spec.chart.url is required")
+		}
+		req.CompositionDefinitionName, req.CompositionDefinitionNamespace = cd.GetName(), cd.GetNamespace()
+		req.CompositionDefinition = cd
+	case body.Chart != nil:
+		if body.Chart.Url == "" {
+			return req, fmt.Errorf("invalid chart: url is required")
+		}
+		cd, err := newCompositionDefinition(req.CompositionDefinitionName, req.CompositionDefinitionNamespace, body.Chart)
+		if err != nil {
+			return req, fmt.Errorf("invalid chart: %w", err)
+		}
+		req.CompositionDefinition = cd
+	default:
+		return req, fmt.Errorf("invalid body: either compositionDefinition or chart is required")
+	}
+
+	return req, readOptions(r, opts, &req)
+}
+
+// kindOf returns the kind of the composition resource gvr, as the cluster
+// serves it or, for a kind the cluster does not serve yet, as the
+// core-provider names it.
+func kindOf(opts handlers.HandlerOptions, gvr schema.GroupVersionResource) string {
+	if opts.RESTMapper != nil {
+		if gvk, err := opts.RESTMapper.KindFor(gvr); err == nil {
+			return gvk.Kind
+		}
+	}
+	return flect.Pascalize(flect.Singularize(gvr.Resource))
+}
+
+func newComposition(gvr schema.GroupVersionResource, kind, name, namespace string, values map[string]any) *unstructured.Unstructured {
+	if values == nil {
+		values = map[string]any{}
+	}
+	res := &unstructured.Unstructured{Object: map[string]any{"spec": values}}
+	res.SetGroupVersionKind(gvr.GroupVersion().WithKind(kind))
+	res.SetName(name)
+	res.SetNamespace(namespace)
+	return res
+}
+
+func newCompositionDefinition(name, namespace string, chart *coreprovv1.ChartInfo) (*unstructured.Unstructured, error) {
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(chart)
+	if err != nil {
+		return nil, err
+	}
+	res := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{"chart": spec},
+	}}
+	res.SetGroupVersionKind(coreprovv1.SchemeGroupVersion.WithKind("CompositionDefinition"))
+	res.SetName(name)
+	res.SetNamespace(namespace)
+	return res, nil
+}
+
+// fallbackPluralizer resolves the kind of an inline composition to the
+// resource it was posted with when the pluralizer cannot, e.g. for a kind
+// the cluster does not serve yet.
+type fallbackPluralizer struct {
+	pluralizer pluralizer
+	gvr        schema.GroupVersionResource
+}
+
+type pluralizer interface {
+	GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error)
+}
+
+func (p fallbackPluralizer) GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	if p.pluralizer != nil {
+		if gvr, err := p.pluralizer.GVKtoGVR(gvk); err == nil {
+			return gvr, nil
+		}
+	}
+	return p.gvr, nil
+}
@@ -0,0 +1,116 @@
+package inspector
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFromBodyValues(t *testing.T) {
+	body := `{"values": {"replicas": 2}, "chart": {"url": "oci://example.io/charts/demo", "version": "1.0.0"}}`
+	r := httptest.NewRequest("POST", "/?compositionName=demo&compositionNamespace=demo-ns&compositionVersion=v1-0-0&compositionResource=demoapps&maxCalls=5", strings.NewReader(body))
+
+	got, err := FromBody(r, handlers.HandlerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGVR := schema.GroupVersionResource{Group: "composition.krateo.io", Version: "v1-0-0", Resource: "demoapps"}
+	if got.CompositionGVR != expectedGVR || got.CompositionName != "demo" || got.CompositionNamespace != "demo-ns" || got.Limits.MaxCalls != 5 {
+		t.Errorf("unexpected request %+v", got)
+	}
+
+	expected := map[string]any{
+		"apiVersion": "composition.krateo.io/v1-0-0",
+		"kind":       "Demoapp",
+		"metadata": map[string]any{
+			"name":      "demo",
+			"namespace": "demo-ns",
+			"labels":    map[string]any{"krateo.io/release-name": "demo"},
+		},
+		"spec": map[string]any{"replicas": float64(2)},
+	}
+	if !reflect.DeepEqual(got.Composition.Object, expected) {
+		t.Errorf("expected composition:\n%v\ngot:\n%v", expected, got.Composition.Object)
+	}
+
+	chart, _, _ := unstructured.NestedMap(got.CompositionDefinition.Object, "spec", "chart")
+	if !reflect.DeepEqual(chart, map[string]any{"url": "oci://example.io/charts/demo", "version": "1.0.0"}) {
+		t.Errorf("unexpected chart %v", chart)
+	}
+}
+
+func TestFromBodyObjects(t *testing.T) {
+	body := `{
+		"composition": {"apiVersion": "composition.krateo.io/v1-0-0", "kind": "DemoApp", "metadata": {"name": "demo", "namespace": "demo-ns", "labels": {"krateo.io/release-name": "demo-release"}}, "spec": {}},
+		"compositionDefinition": {"apiVersion": "core.krateo.io/v1alpha1", "kind": "CompositionDefinition", "metadata": {"name": "cd", "namespace": "cd-ns"}, "spec": {"chart": {"url": "oci://example.io/charts/demo"}}}
+	}`
+	got, err := FromBody(httptest.NewRequest("POST", "/", strings.NewReader(body)), handlers.HandlerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGVR := schema.GroupVersionResource{Group: "composition.krateo.io", Version: "v1-0-0", Resource: "demoapps"}
+	if got.CompositionGVR != expectedGVR || got.CompositionName != "demo" || got.CompositionNamespace != "demo-ns" {
+		t.Errorf("unexpected composition identity %+v", got)
+	}
+	if got.CompositionDefinitionName != "cd" || got.CompositionDefinitionNamespace != "cd-ns" {
+		t.Errorf("unexpected composition definition identity %+v", got)
+	}
+	if name := got.Composition.GetLabels()["krateo.io/release-name"]; name != "demo-release" {
+		t.Errorf("expected the release name demo-release to be kept, got %q", name)
+	}
+}
+
+func TestFromBodyErrors(t *testing.T) {
+	chart := `"chart": {"url": "oci://example.io/charts/demo"}`
+	query := "/?compositionName=demo&compositionNamespace=demo-ns&compositionVersion=v1&compositionResource=demos"
+	tests := []struct {
+		name  string
+		url   string
+		body  string
+		error string
+	}{
+		{name: "not json", url: query, body: `values`, error: "unable to decode the inline objects: invalid character 'v' looking for beginning of value"},
+		{name: "composition and values", url: query, body: `{"composition": {}, "values": {}, ` + chart + `}`, error: "invalid body: composition and values are mutually exclusive"},
+		{name: "incomplete composition", url: query, body: `{"composition": {"kind": "Demo"}, ` + chart + `}`, error: "invalid composition: apiVersion, kind, metadata.name and metadata.namespace are required"},
+		{name: "composition name outside the cassette directory", url: query, body: `{"composition": {"apiVersion": "composition.krateo.io/v1", "kind": "Demo", "metadata": {"name": "../../../tmp/x", "namespace": "demo-ns"}}, ` + chart + `}`, error: `invalid composition: invalid composition name "../../../tmp/x": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`},
+		{name: "invalid composition namespace", url: query, body: `{"composition": {"apiVersion": "composition.krateo.io/v1", "kind": "Demo", "metadata": {"name": "demo", "namespace": "../demo"}}, ` + chart + `}`, error: `invalid composition: invalid composition namespace "../demo": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`},
+		{name: "missing parameters", url: "/", body: `{` + chart + `}`, error: "missing required query parameters: the composition is not in the body"},
+		{name: "definition and chart", url: query, body: `{"compositionDefinition": {}, ` + chart + `}`, error: "invalid body: compositionDefinition and chart are mutually exclusive"},
+		{name: "no chart", url: query, body: `{}`, error: "invalid body: either compositionDefinition or chart is required"},
+		{name: "definition without chart", url: query, body: `{"compositionDefinition": {"metadata": {"name": "cd", "namespace": "cd-ns"}}}`, error: "invalid compositionDefinition: spec.chart.url is required"},
+		{name: "definition without chart url", url: query, body: `{"compositionDefinition": {"spec": {"chart": {"version": "0.1.0"}}}}`, error: "invalid compositionDefinition: spec.chart.url is required"},
+		{name: "no chart url", url: query, body: `{"chart": {}}`, error: "invalid chart: url is required"},
+		{name: "invalid options", url: query + "&maxCalls=-1", body: `{` + chart + `}`, error: `invalid maxCalls "-1", must be a non-negative integer`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromBody(httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body)), handlers.HandlerOptions{})
+			if err == nil || err.Error() != tt.error {
+				t.Errorf("expected error %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+type failingPluralizer struct{}
+
+func (failingPluralizer) GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	return schema.GroupVersionResource{}, errors.New("unknown kind")
+}
+
+func TestFallbackPluralizer(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "composition.krateo.io", Version: "v1", Resource: "demos"}
+	got, err := fallbackPluralizer{pluralizer: failingPluralizer{}, gvr: gvr}.GVKtoGVR(gvr.GroupVersion().WithKind("Demo"))
+	if err != nil || got != gvr {
+		t.Errorf("expected %v, got %v (%v)", gvr, got, err)
+	}
+}
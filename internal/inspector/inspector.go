@@ -0,0 +1,567 @@
+// Package inspector runs the traced Helm dry-run of a composition that the
+// endpoints of the service report on.
+package inspector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	coreprovv1 "github.com/krateoplatformops/core-provider/apis/compositiondefinitions/v1alpha1"
+
+	"github.com/krateoplatformops/chart-inspector/internal/getter"
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	"github.com/krateoplatformops/chart-inspector/internal/helper"
+	"github.com/krateoplatformops/chart-inspector/internal/manifest"
+	"github.com/krateoplatformops/chart-inspector/internal/tracer"
+	compositionMeta "github.com/krateoplatformops/composition-dynamic-controller/pkg/meta"
+	helmconfig "github.com/krateoplatformops/plumbing/helm"
+	helmutils "github.com/krateoplatformops/plumbing/helm/utils"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Values of the cassette query parameter.
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// HeaderTruncated is set on a response when the tracer reached its limits and
+// the result lacks some calls.
+const HeaderTruncated = "X-Chart-Inspector-Truncated"
+
+// ErrCassette is returned by Run when the cassette to replay cannot be loaded.
+var ErrCassette = errors.New("unable to load cassette")
+
+// ErrCassetteTruncated is returned by Run when the dry-run to record reached
+// the limits of the tracer: the cassette is not written.
+var ErrCassetteTruncated = errors.New("unable to record cassette")
+
+// ErrCassetteDir is returned by Run when the cassette of the composition would
+// not be in the cassette directory.
+var ErrCassetteDir = errors.New("invalid cassette path")
+
+// StatusCode returns the status code a handler answers with when Run failed
+// with err.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrCassette):
+		return http.StatusNotFound
+	case errors.Is(err, ErrCassetteDir):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrCassetteTruncated):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// Request identifies the composition to inspect and how to trace its
+// dry-run.
+type Request struct {
+	CompositionGVR                 schema.GroupVersionResource
+	CompositionName                string
+	CompositionNamespace           string
+	CompositionDefinitionGVR       schema.GroupVersionResource
+	CompositionDefinitionName      string
+	CompositionDefinitionNamespace string
+	// Composition and CompositionDefinition, when set, are inspected in
+	// place of the objects of the cluster they identify.
+	Composition           *unstructured.Unstructured
+	CompositionDefinition *unstructured.Unstructured
+	// Limits are the limits of the tracer.
+	Limits tracer.Limits
+	// Cassette is CassetteRecord or CassetteReplay to record the dry-run to,
+	// or replay it from, the cassette directory of the service.
+	Cassette string
+	// Capture makes the tracer keep the full exchanges, see
+	// tracer.WithCapture.
+	Capture bool
+	// Impersonate is the identity the dry-run is made as, when its UserName is
+	// set, instead of the identity of the service.
+	Impersonate rest.ImpersonationConfig
+	// Transport, when set, wraps the transport of the dry-run below the
+	// tracer, so that the tracer sees its responses.
+	Transport func(rt http.RoundTripper) http.RoundTripper
+	// RenderHooks makes Run also render the hooks and the NOTES.txt of the
+	// chart, with a second dry-run whose calls are not traced.
+	RenderHooks bool
+}
+
+// FromQuery reads the request out of the query parameters of r, the
+// defaults coming from opts. The returned Request holds the parameters read
+// so far when the error is not nil.
+func FromQuery(r *http.Request, opts handlers.HandlerOptions) (Request, error) {
+	req := Request{
+		CompositionGVR: schema.GroupVersionResource{
+			Group:    helper.GetQueryParamWithDefault(r, "compositionGroup", "composition.krateo.io"),
+			Version:  r.URL.Query().Get("compositionVersion"),
+			Resource: r.URL.Query().Get("compositionResource"),
+		},
+		CompositionName:      r.URL.Query().Get("compositionName"),
+		CompositionNamespace: r.URL.Query().Get("compositionNamespace"),
+		CompositionDefinitionGVR: schema.GroupVersionResource{
+			Group:    helper.GetQueryParamWithDefault(r, "compositionDefinitionGroup", "core.krateo.io"),
+			Version:  helper.GetQueryParamWithDefault(r, "compositionDefinitionVersion", "v1alpha1"),
+			Resource: helper.GetQueryParamWithDefault(r, "compositionDefinitionResource", "compositiondefinitions"),
+		},
+		CompositionDefinitionName:      r.URL.Query().Get("compositionDefinitionName"),
+		CompositionDefinitionNamespace: r.URL.Query().Get("compositionDefinitionNamespace"),
+	}
+
+	if req.CompositionName == "" || req.CompositionNamespace == "" || req.CompositionDefinitionName == "" || req.CompositionDefinitionNamespace == "" || req.CompositionGVR.Version == "" || req.CompositionGVR.Resource == "" {
+		return req, fmt.Errorf("missing required query parameters")
+	}
+
+	return req, readOptions(r, opts, &req)
+}
+
+// readOptions reads the options of the dry-run of req out of the query
+// parameters of r, the defaults coming from opts.
+func readOptions(r *http.Request, opts handlers.HandlerOptions, req *Request) error {
+	if err := validateComposition(req.CompositionName, req.CompositionNamespace); err != nil {
+		return err
+	}
+
+	req.Cassette = r.URL.Query().Get("cassette")
+
+	var err error
+	req.Limits.MaxCalls, err = helper.GetQueryParamInt(r, "maxCalls", opts.Limits.MaxCalls)
+	if err != nil || req.Limits.MaxCalls < 0 {
+		return fmt.Errorf("invalid maxCalls %q, must be a non-negative integer", r.URL.Query().Get("maxCalls"))
+	}
+	req.Limits.MaxBodyBytes, err = helper.GetQueryParamInt(r, "maxBodyBytes", opts.Limits.MaxBodyBytes)
+	if err != nil || req.Limits.MaxBodyBytes < 0 {
+		return fmt.Errorf("invalid maxBodyBytes %q, must be a non-negative integer", r.URL.Query().Get("maxBodyBytes"))
+	}
+
+	if ref := r.URL.Query().Get("impersonate"); ref != "" {
+		namespace, name, ok := strings.Cut(ref, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("invalid impersonate %q, must be the namespace/name of a ServiceAccount", ref)
+		}
+		req.Impersonate = ServiceAccount(namespace, name)
+	}
+
+	switch req.Cassette {
+	case "":
+	case CassetteRecord, CassetteReplay:
+		if opts.CassetteDir == "" {
+			return fmt.Errorf("cassettes are disabled: no cassette directory is configured")
+		}
+	default:
+		return fmt.Errorf("invalid cassette %q, must be one of %v", req.Cassette, []string{CassetteRecord, CassetteReplay})
+	}
+
+	return nil
+}
+
+// validateComposition checks that the name and namespace of a composition are
+// valid object names, as the API server requires: they also name the file of
+// its cassette.
+func validateComposition(name, namespace string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid composition name %q: %s", name, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid composition namespace %q: %s", namespace, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// cassettePath returns the path of the cassette of the composition of req in
+// dir. It fails when the path would not be in dir.
+func cassettePath(dir string, req Request) (string, error) {
+	dir = filepath.Clean(dir)
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.har", req.CompositionNamespace, req.CompositionName))
+	if rel, err := filepath.Rel(dir, path); err != nil || !filepath.IsLocal(rel) || filepath.Dir(rel) != "." {
+		return "", fmt.Errorf("%w %q: not in the cassette directory %q", ErrCassetteDir, path, dir)
+	}
+	return path, nil
+}
+
+// ServiceAccount returns the impersonation of the ServiceAccount name in
+// namespace, with the groups the API server authenticates it with.
+func ServiceAccount(namespace, name string) rest.ImpersonationConfig {
+	return rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name),
+		Groups: []string{
+			"system:serviceaccounts",
+			"system:serviceaccounts:" + namespace,
+			"system:authenticated",
+		},
+	}
+}
+
+// Logger returns log with the identity of the composition of req.
+func (req Request) Logger(log *slog.Logger) *slog.Logger {
+	return log.With(
+		slog.String("compositionName", req.CompositionName),
+		slog.String("compositionNamespace", req.CompositionNamespace),
+		slog.String("compositionDefinitionName", req.CompositionDefinitionName),
+		slog.String("compositionDefinitionNamespace", req.CompositionDefinitionNamespace),
+	)
+}
+
+// Inspection is the outcome of the dry-run of a composition.
+type Inspection struct {
+	Composition           *unstructured.Unstructured
+	CompositionDefinition *unstructured.Unstructured
+	// Tracer is the tracer of the dry-run, holding its calls and, when
+	// capturing, its exchanges.
+	Tracer *tracer.Tracer
+	// Resources are the traced resources, with their kind and scope resolved
+	// and, for calls on rendered objects, their template.
+	Resources []resources.Resource
+	// Rendered are the objects of the release manifest, placed in the
+	// composition namespace when the chart does not set one.
+	Rendered []resources.Resource
+	// Manifest is the release manifest: the rendered templates, hooks
+	// excluded, as a multi-document YAML stream. It is empty when the dry-run
+	// failed.
+	Manifest string
+	// InstallErr is the error the dry-run failed with, e.g. an admission
+	// rejection. The traced resources are still those of the failed run.
+	InstallErr error
+	// Hooks and Notes are the hooks and the NOTES.txt the chart renders,
+	// with Request.RenderHooks and a successful dry-run.
+	Hooks []Hook
+	Notes string
+	// ManifestErr is set when the release manifest could not be parsed;
+	// Rendered is then incomplete.
+	ManifestErr error
+	// Phases are the durations of the phases of the inspection.
+	Phases []resources.Phase
+}
+
+// Run fetches the composition and its definition and installs the chart with
+// a server-side dry-run, tracing the calls it makes to the API server. It
+// only fails when the dry-run could not be attempted: a failed dry-run is
+// reported in the InstallErr of the Inspection. Errors are logged to log.
+func Run(ctx context.Context, opts handlers.HandlerOptions, req Request, log *slog.Logger) (*Inspection, error) {
+	sw := newStopwatch()
+
+	var cassette string
+	var replay *tracer.Cassette
+	var err error
+	if req.Cassette != "" {
+		cassette, err = cassettePath(opts.CassetteDir, req)
+		if err != nil {
+			log.Error("invalid cassette", slog.Any("err", err))
+			return nil, err
+		}
+	}
+	if req.Cassette == CassetteReplay {
+		replay, err = tracer.LoadCassette(cassette)
+		if err != nil {
+			log.Error("unable to load cassette",
+				slog.String("path", cassette),
+				slog.Any("err", err),
+			)
+			return nil, fmt.Errorf("%w: %w", ErrCassette, err)
+		}
+	}
+
+	// The reads of the objects the dry-run is made for, and the dry-run of
+	// the hooks, are recorded and replayed along with it, but not traced as
+	// its calls.
+	fixtures := (&tracer.Tracer{}).WithCapture()
+	dyn, err := objectClient(opts, req, fixtures, replay)
+	if err != nil {
+		log.Error("unable to create dynamic client", slog.Any("err", err))
+		return nil, err
+	}
+
+	composition, err := getComposition(ctx, dyn, req, log)
+	if err != nil {
+		return nil, err
+	}
+	sw.lap(phaseComposition)
+
+	bValuesMap, err := buildValues(composition, opts, req, log)
+	if err != nil {
+		return nil, err
+	}
+	sw.lap(phaseValues)
+
+	tr := (&tracer.Tracer{}).
+		WithRelease(compositionMeta.GetReleaseName(composition), req.CompositionNamespace).
+		WithLimits(req.Limits)
+	if req.Capture || req.Cassette == CassetteRecord {
+		tr.WithCapture()
+	}
+	// Create a wrapped REST config with the tracer RoundTripper for this request
+	wrappedCfg := rest.CopyConfig(opts.RestConfig)
+	wrappedCfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if req.Transport != nil {
+			rt = req.Transport(rt)
+		}
+		return tr.WithRoundTripper(rt)
+	}
+
+	if req.Impersonate.UserName != "" {
+		log.Info("Impersonating", slog.String("user", req.Impersonate.UserName))
+		wrappedCfg.Impersonate = req.Impersonate
+	}
+
+	if replay != nil {
+		replayConfig(wrappedCfg, replay)
+	}
+
+	compositionDefinitionU, compositionDefinition, err := getCompositionDefinition(ctx, dyn, req, log)
+	if err != nil {
+		return nil, err
+	}
+	if compositionDefinition.Spec.Chart == nil {
+		err := fmt.Errorf("composition definition %s has no chart", compositionDefinitionU.GetName())
+		log.Error("invalid composition definition", slog.Any("err", err))
+		return nil, err
+	}
+	sw.lap(phaseCompositionDefinition)
+
+	// Install the Helm chart with DryRun to capture templated resources
+	// Set namespace to composition's namespace for proper resource isolation
+	installCfg := &helmconfig.InstallConfig{
+		ActionConfig: &helmconfig.ActionConfig{
+			ChartVersion:          compositionDefinition.Spec.Chart.Version,
+			ChartName:             compositionDefinition.Spec.Chart.Repo,
+			Values:                bValuesMap,
+			Username:              "",
+			Password:              "",
+			InsecureSkipTLSverify: compositionDefinition.Spec.Chart.InsecureSkipVerifyTLS,
+			DryRun:                helmconfig.DryRunServer,
+			IncludeCRDs:           true,
+			SkipCRDs:              false,
+		},
+		Namespace:       req.CompositionNamespace, // Override namespace for this composition
+		CreateNamespace: true,
+		RestConfig:      wrappedCfg, // Pass wrapped config with tracer integration
+	}
+
+	// Retrieve credentials from secret if specified
+	if compositionDefinition.Spec.Chart.Credentials != nil {
+		installCfg.ActionConfig.Username, installCfg.ActionConfig.Password, err = credentials(dyn, compositionDefinition, log)
+		if err != nil {
+			return nil, err
+		}
+		sw.lap(phaseCredentials)
+	}
+
+	// Install with DryRun to get templated manifest using global helm client with tracer integration
+	rel, installErr := opts.HelmClient.Install(ctx, compositionMeta.GetReleaseName(composition), compositionDefinition.Spec.Chart.Url, installCfg)
+	sw.lap(phaseInstall)
+
+	// The hooks are rendered before the cassette is saved, which records
+	// their dry-run along with the reads of the objects.
+	var hooks []Hook
+	var notes string
+	if req.RenderHooks && installErr == nil {
+		chrt, err := pullChart(ctx, opts, compositionDefinition.Spec.Chart, installCfg.ActionConfig.Username, installCfg.ActionConfig.Password, log)
+		if err != nil {
+			return nil, err
+		}
+		cfg := objectConfig(opts, req, fixtures, replay)
+		cfg.Impersonate = req.Impersonate
+		hooks, notes, err = renderHooks(ctx, cfg, chrt, compositionMeta.GetReleaseName(composition), req.CompositionNamespace, bValuesMap)
+		if err != nil {
+			log.Error("unable to render hooks", slog.Any("err", err))
+			return nil, fmt.Errorf("unable to render hooks: %w", err)
+		}
+		sw.lap(phaseHooks)
+	}
+
+	if req.Cassette == CassetteRecord {
+		if err := tr.SaveCassette(cassette, fixtures); err != nil {
+			log.Error("unable to save cassette",
+				slog.String("path", cassette),
+				slog.Any("err", err),
+			)
+			if errors.Is(err, tracer.ErrTruncated) {
+				return nil, fmt.Errorf("%w: %w: record it with higher maxCalls and maxBodyBytes, or 0 for no limit", ErrCassetteTruncated, err)
+			}
+			return nil, err
+		}
+		log.Info("Recorded dry-run", slog.String("path", cassette))
+	}
+
+	if installErr != nil {
+		log.Error("unable to template chart",
+			slog.Any("err", installErr),
+		)
+	}
+
+	res := &Inspection{
+		Composition:           composition,
+		CompositionDefinition: compositionDefinitionU,
+		Tracer:                tr,
+		Resources:             tr.GetResources(),
+		InstallErr:            installErr,
+		Hooks:                 hooks,
+		Notes:                 notes,
+	}
+
+	// The release manifest tells which template rendered each object.
+	if rel != nil {
+		res.Manifest = rel.Manifest
+		objs, err := manifest.Parse(rel.Manifest)
+		if err != nil {
+			log.Error("unable to parse release manifest",
+				slog.Any("err", err),
+			)
+			res.ManifestErr = err
+		}
+		res.Rendered = manifestResources(objs, opts.Plurarizer)
+	}
+	if opts.RESTMapper != nil {
+		for _, gvr := range resolveKinds(res.Resources, opts.RESTMapper) {
+			log.Debug("unable to resolve the kind of a resource", slog.String("resource", gvr.String()))
+		}
+		resolveKinds(res.Rendered, opts.RESTMapper)
+	}
+	placeRendered(res.Rendered, req.CompositionNamespace)
+	annotateTemplates(res.Resources, res.Rendered)
+
+	res.Phases = sw.stop()
+
+	return res, nil
+}
+
+// objectClient returns the client the composition, its definition and the
+// credentials of its chart are read with: the one of opts, unless req records
+// or replays a cassette, see objectConfig.
+func objectClient(opts handlers.HandlerOptions, req Request, fixtures *tracer.Tracer, replay *tracer.Cassette) (dynamic.Interface, error) {
+	if replay == nil && req.Cassette != CassetteRecord {
+		return opts.DynamicClient, nil
+	}
+	return dynamic.NewForConfig(objectConfig(opts, req, fixtures, replay))
+}
+
+// objectConfig returns a copy of the config of opts for the calls that are
+// not traced: when req records a cassette, they are captured by fixtures, when
+// it replays replay, they are served from its fixtures.
+func objectConfig(opts handlers.HandlerOptions, req Request, fixtures *tracer.Tracer, replay *tracer.Cassette) *rest.Config {
+	cfg := rest.CopyConfig(opts.RestConfig)
+	switch {
+	case replay != nil:
+		replayConfig(cfg, replay.Fixtures())
+	case req.Cassette == CassetteRecord:
+		cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return fixtures.WithRoundTripper(rt)
+		}
+	}
+	return cfg
+}
+
+// replayConfig makes cfg send its requests to cassette.
+func replayConfig(cfg *rest.Config, cassette *tracer.Cassette) {
+	cfg.Transport = cassette
+	// A custom transport cannot be combined with TLS options.
+	cfg.TLSClientConfig = rest.TLSClientConfig{}
+}
+
+// getComposition returns the composition of req, fetching it with dyn unless
+// it was posted inline.
+func getComposition(ctx context.Context, dyn dynamic.Interface, req Request, log *slog.Logger) (*unstructured.Unstructured, error) {
+	if req.Composition != nil {
+		return req.Composition, nil
+	}
+	composition, err := dyn.
+		Resource(req.CompositionGVR).
+		Namespace(req.CompositionNamespace).
+		Get(ctx, req.CompositionName, v1.GetOptions{})
+	if err != nil {
+		log.Error("unable to get composition",
+			slog.String("compositionName", req.CompositionName),
+			slog.String("compositionNamespace", req.CompositionNamespace),
+			slog.String("compositionVersion", req.CompositionGVR.Version),
+			slog.String("compositionResource", req.CompositionGVR.Resource),
+			slog.String("compositionGroup", req.CompositionGVR.Group),
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+	return composition, nil
+}
+
+// buildValues returns the values the composition-dynamic-controller installs the
+// chart of composition with: its spec, with the global values injected.
+func buildValues(composition *unstructured.Unstructured, opts handlers.HandlerOptions, req Request, log *slog.Logger) (helmutils.Values, error) {
+	// NOTE: bValues are extracted and injected with composition context
+	bValuesMap, err := helmutils.ValuesFromSpec(composition)
+	if err != nil {
+		log.Error("unable to extract values from composition",
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+	var pluralizer pluralizer = opts.Plurarizer
+	if req.Composition != nil {
+		pluralizer = fallbackPluralizer{pluralizer: opts.Plurarizer, gvr: req.CompositionGVR}
+	}
+	err = bValuesMap.InjectGlobalValues(composition, pluralizer, opts.KrateoNamespace)
+	if err != nil {
+		log.Error("unable to inject global values",
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+	return bValuesMap, nil
+}
+
+// getCompositionDefinition returns the composition definition of req,
+// fetching it with dyn unless it was posted inline, both as it is and
+// converted.
+func getCompositionDefinition(ctx context.Context, dyn dynamic.Interface, req Request, log *slog.Logger) (*unstructured.Unstructured, *coreprovv1.CompositionDefinition, error) {
+	var err error
+	compositionDefinitionU := req.CompositionDefinition
+	if compositionDefinitionU == nil {
+		compositionDefinitionU, err = dyn.
+			Resource(req.CompositionDefinitionGVR).
+			Namespace(req.CompositionDefinitionNamespace).
+			Get(ctx, req.CompositionDefinitionName, v1.GetOptions{})
+	}
+	if err != nil {
+		log.Error("unable to get composition definition",
+			slog.Any("err", err),
+		)
+		return nil, nil, err
+	}
+	var compositionDefinition coreprovv1.CompositionDefinition
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(compositionDefinitionU.Object, &compositionDefinition)
+	if err != nil {
+		log.Error("unable to convert composition definition",
+			slog.Any("err", err),
+		)
+		return nil, nil, err
+	}
+	return compositionDefinitionU, &compositionDefinition, nil
+}
+
+// credentials returns the username and password the chart of
+// compositionDefinition is pulled with, reading the password from its Secret
+// with dyn.
+func credentials(dyn dynamic.Interface, compositionDefinition *coreprovv1.CompositionDefinition, log *slog.Logger) (string, string, error) {
+	k8scli := getter.NewClient(
+		dyn,
+	)
+
+	// Retrieve password from secret
+	passwd, err := k8scli.GetSecret(compositionDefinition.Spec.Chart.Credentials.PasswordRef)
+	if err != nil {
+		log.Error("unable to get secret",
+			slog.Any("err", err),
+		)
+		return "", "", err
+	}
+	return compositionDefinition.Spec.Chart.Credentials.Username, passwd, nil
+}
@@ -0,0 +1,104 @@
+package inspector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/tracer"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+const composition = "/?compositionName=demo&compositionNamespace=demo-ns&compositionDefinitionName=cd&compositionDefinitionNamespace=cd-ns&compositionVersion=v1alpha1&compositionResource=demos"
+
+func TestFromQuery(t *testing.T) {
+	opts := handlers.HandlerOptions{CassetteDir: "/cassettes", Limits: tracer.Limits{MaxCalls: 10, MaxBodyBytes: 20}}
+
+	got, err := FromQuery(httptest.NewRequest("GET", composition+"&maxCalls=5&impersonate=cd-ns/cdc&cassette=replay", nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Request{
+		CompositionGVR:                 schema.GroupVersionResource{Group: "composition.krateo.io", Version: "v1alpha1", Resource: "demos"},
+		CompositionName:                "demo",
+		CompositionNamespace:           "demo-ns",
+		CompositionDefinitionGVR:       schema.GroupVersionResource{Group: "core.krateo.io", Version: "v1alpha1", Resource: "compositiondefinitions"},
+		CompositionDefinitionName:      "cd",
+		CompositionDefinitionNamespace: "cd-ns",
+		Limits:                         tracer.Limits{MaxCalls: 5, MaxBodyBytes: 20},
+		Cassette:                       CassetteReplay,
+		Impersonate: rest.ImpersonationConfig{
+			UserName: "system:serviceaccount:cd-ns:cdc",
+			Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:cd-ns", "system:authenticated"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected:\n%+v\ngot:\n%+v", expected, got)
+	}
+}
+
+func TestFromQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		opts  handlers.HandlerOptions
+		error string
+	}{
+		{name: "missing parameters", url: "/?compositionName=demo", error: "missing required query parameters"},
+		{name: "invalid composition name", url: "/?compositionName=..%2Fx&compositionNamespace=demo-ns&compositionDefinitionName=cd&compositionDefinitionNamespace=cd-ns&compositionVersion=v1alpha1&compositionResource=demos", error: `invalid composition name "../x": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`},
+		{name: "negative maxCalls", url: composition + "&maxCalls=-1", error: `invalid maxCalls "-1", must be a non-negative integer`},
+		{name: "invalid maxBodyBytes", url: composition + "&maxBodyBytes=x", error: `invalid maxBodyBytes "x", must be a non-negative integer`},
+		{name: "invalid impersonate", url: composition + "&impersonate=cdc", error: `invalid impersonate "cdc", must be the namespace/name of a ServiceAccount`},
+		{name: "cassettes disabled", url: composition + "&cassette=record", error: "cassettes are disabled: no cassette directory is configured"},
+		{name: "invalid cassette", url: composition + "&cassette=rewind", opts: handlers.HandlerOptions{CassetteDir: "/cassettes"}, error: `invalid cassette "rewind", must be one of [record replay]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromQuery(httptest.NewRequest("GET", tt.url, nil), tt.opts)
+			if err == nil || err.Error() != tt.error {
+				t.Errorf("expected error %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestCassettePath(t *testing.T) {
+	got, err := cassettePath("/cassettes/", Request{CompositionName: "demo", CompositionNamespace: "demo-ns"})
+	if err != nil || got != "/cassettes/demo-ns_demo.har" {
+		t.Errorf("expected /cassettes/demo-ns_demo.har, got %q (%v)", got, err)
+	}
+
+	for _, req := range []Request{
+		{CompositionName: "../../../tmp/x", CompositionNamespace: "demo-ns"},
+		{CompositionName: "x", CompositionNamespace: "../../tmp"},
+		{CompositionName: "x/../../y", CompositionNamespace: "demo-ns"},
+	} {
+		if got, err := cassettePath("/cassettes", req); !errors.Is(err, ErrCassetteDir) {
+			t.Errorf("expected an error for %s/%s, got %q (%v)", req.CompositionNamespace, req.CompositionName, got, err)
+		}
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{err: fmt.Errorf("%w: no such file", ErrCassette), expected: http.StatusNotFound},
+		{err: fmt.Errorf("%w %q", ErrCassetteDir, "/tmp/x.har"), expected: http.StatusBadRequest},
+		{err: fmt.Errorf("%w: limits reached", ErrCassetteTruncated), expected: http.StatusUnprocessableEntity},
+		{err: errors.New("connection refused"), expected: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := StatusCode(tt.err); got != tt.expected {
+			t.Errorf("%v: expected status %d, got %d", tt.err, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package inspector
+
+import (
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kindResolver resolves resources to their kind and scope, e.g. a RESTMapper
+// backed by discovery.
+type kindResolver interface {
+	KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error)
+	RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error)
+}
+
+// kindMapping is the kind and scope of a resource.
+type kindMapping struct {
+	kind       string
+	namespaced *bool
+}
+
+// resolveKinds sets the Kind and Namespaced fields of the entries of li from
+// mapper. The entries of a subresource get those of their parent resource.
+// Resources unknown to mapper keep the kind decoded from the call body, if
+// any, and no scope. It returns the resources that could not be resolved.
+func resolveKinds(li []resources.Resource, mapper kindResolver) []schema.GroupVersionResource {
+	var unresolved []schema.GroupVersionResource
+	cache := map[schema.GroupVersionResource]*kindMapping{}
+	for i := range li {
+		gvr := schema.GroupVersionResource{Group: li[i].Group, Version: li[i].Version, Resource: li[i].Resource}
+
+		m, seen := cache[gvr]
+		if !seen {
+			m = resolveKind(mapper, gvr)
+			cache[gvr] = m
+			if m == nil {
+				unresolved = append(unresolved, gvr)
+			}
+		}
+		if m == nil {
+			continue
+		}
+
+		li[i].Kind = m.kind
+		li[i].Namespaced = m.namespaced
+	}
+
+	return unresolved
+}
+
+// resolveKind returns the kind and scope of gvr, or nil when mapper does not
+// know it.
+func resolveKind(mapper kindResolver, gvr schema.GroupVersionResource) *kindMapping {
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return nil
+	}
+
+	res := &kindMapping{kind: gvk.Kind}
+	rm, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return res
+	}
+	namespaced := rm.Scope.Name() == meta.RESTScopeNameNamespace
+	res.namespaced = &namespaced
+
+	return res
+}
@@ -0,0 +1,45 @@
+package inspector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResolveKinds(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+
+	li := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web"},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Subresource: "scale"},
+		{Version: "v1", Resource: "namespaces", Name: "demo"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+		{Group: "example.io", Version: "v1", Resource: "widgets", Namespace: "demo", Name: "w", Kind: "Widget"},
+		{Group: "example.io", Version: "v1", Resource: "widgets", Namespace: "demo"},
+	}
+
+	unresolved := resolveKinds(li, mapper)
+
+	namespaced, cluster := true, false
+	expected := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Kind: "Deployment", Namespaced: &namespaced},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Subresource: "scale", Kind: "Deployment", Namespaced: &namespaced},
+		{Version: "v1", Resource: "namespaces", Name: "demo", Kind: "Namespace", Namespaced: &cluster},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Kind: "ClusterRole", Namespaced: &cluster},
+		{Group: "example.io", Version: "v1", Resource: "widgets", Namespace: "demo", Name: "w", Kind: "Widget"},
+		{Group: "example.io", Version: "v1", Resource: "widgets", Namespace: "demo"},
+	}
+	if !reflect.DeepEqual(li, expected) {
+		t.Errorf("expected %+v, got %+v", expected, li)
+	}
+
+	if expected := []schema.GroupVersionResource{{Group: "example.io", Version: "v1", Resource: "widgets"}}; !reflect.DeepEqual(unresolved, expected) {
+		t.Errorf("expected unresolved %v, got %v", expected, unresolved)
+	}
+}
@@ -0,0 +1,154 @@
+package inspector
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/flect"
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	"github.com/krateoplatformops/chart-inspector/internal/manifest"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// gvrResolver maps kinds to resources, e.g. the pluralizer.
+type gvrResolver interface {
+	GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error)
+}
+
+// manifestResources converts the objects of a release manifest to entries.
+// A kind the resolver does not know (e.g. the kind of a CRD the chart itself
+// installs) is pluralized by name, as the API server does by default.
+func manifestResources(objs []manifest.Object, p gvrResolver) []resources.Resource {
+	res := []resources.Resource{}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		gvr, err := p.GVKtoGVR(gvk)
+		if err != nil {
+			gvr = gvk.GroupVersion().WithResource(strings.ToLower(flect.Pluralize(gvk.Kind)))
+		}
+
+		template, subchart := splitSource(obj.Source)
+		res = append(res, resources.Resource{
+			Group:     gvr.Group,
+			Version:   gvr.Version,
+			Resource:  gvr.Resource,
+			Name:      obj.Name,
+			Namespace: obj.Namespace,
+			Kind:      obj.Kind,
+			Origin:    resources.OriginRendered,
+			Source:    obj.Source,
+			Template:  template,
+			Subchart:  subchart,
+		})
+	}
+	return res
+}
+
+// splitSource splits the path of a template as written in a release manifest,
+// e.g. "app/charts/redis/templates/service.yaml", into the path of the
+// template within its chart, "templates/service.yaml", and the subchart it
+// belongs to, "redis". Nested subcharts are joined with a slash; the
+// subchart is empty for a template of the chart itself.
+func splitSource(source string) (template, subchart string) {
+	parts := strings.Split(source, "/")
+	if len(parts) < 2 {
+		return source, ""
+	}
+
+	// Skip the name of the chart.
+	parts = parts[1:]
+	var subcharts []string
+	for len(parts) > 2 && parts[0] == "charts" {
+		subcharts = append(subcharts, parts[1])
+		parts = parts[2:]
+	}
+
+	return strings.Join(parts, "/"), strings.Join(subcharts, "/")
+}
+
+// placeRendered places the rendered objects without a namespace in
+// releaseNamespace, as Helm does, unless they are known to be cluster-scoped.
+func placeRendered(rendered []resources.Resource, releaseNamespace string) {
+	for i := range rendered {
+		if rendered[i].Namespace == "" && (rendered[i].Namespaced == nil || *rendered[i].Namespaced) {
+			rendered[i].Namespace = releaseNamespace
+		}
+	}
+}
+
+// matchRendered returns, for each entry of traffic, the index of the rendered
+// object it is a call on, or -1. Collections and subresources never match,
+// and the API version is ignored, since an object can be read through any of
+// the versions of its resource.
+func matchRendered(traffic, rendered []resources.Resource) []int {
+	type key struct {
+		group, resource, namespace, name string
+	}
+
+	index := map[key]int{}
+	for i, el := range rendered {
+		k := key{el.Group, el.Resource, el.Namespace, el.Name}
+		if _, ok := index[k]; !ok {
+			index[k] = i
+		}
+	}
+
+	res := make([]int, len(traffic))
+	for i, el := range traffic {
+		res[i] = -1
+		if el.Name == "" || el.Subresource != "" {
+			continue
+		}
+		if j, ok := index[key{el.Group, el.Resource, el.Namespace, el.Name}]; ok {
+			res[i] = j
+		}
+	}
+	return res
+}
+
+// annotateTemplates sets the template Source, Template and Subchart of the
+// traced entries that are calls on a rendered object.
+func annotateTemplates(traffic, rendered []resources.Resource) {
+	for i, j := range matchRendered(traffic, rendered) {
+		if j < 0 {
+			continue
+		}
+		traffic[i].Source = rendered[j].Source
+		traffic[i].Template = rendered[j].Template
+		traffic[i].Subchart = rendered[j].Subchart
+	}
+}
+
+// Merge unions the traced entries with the objects of the release
+// manifest rendered, marking where each entry was seen. A traced entry
+// matching a rendered object gets its template and, being a call on an
+// object the chart owns, the rendered origin. The rendered objects the
+// dry-run never touched are appended.
+func Merge(traffic, rendered []resources.Resource) []resources.Resource {
+	res := make([]resources.Resource, 0, len(traffic)+len(rendered))
+	touched := make([]bool, len(rendered))
+	for i, j := range matchRendered(traffic, rendered) {
+		el := traffic[i]
+		el.Seen = resources.SeenTraffic
+		if j >= 0 {
+			touched[j] = true
+			el.Seen = resources.SeenBoth
+			el.Source = rendered[j].Source
+			el.Template = rendered[j].Template
+			el.Subchart = rendered[j].Subchart
+			if el.Origin != resources.OriginHelm {
+				el.Origin = resources.OriginRendered
+			}
+		}
+		res = append(res, el)
+	}
+
+	for i, el := range rendered {
+		if touched[i] {
+			continue
+		}
+		el.Seen = resources.SeenManifest
+		res = append(res, el)
+	}
+
+	return res
+}
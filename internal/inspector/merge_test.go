@@ -0,0 +1,118 @@
+package inspector
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	"github.com/krateoplatformops/chart-inspector/internal/manifest"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// staticPluralizer is a mock pluralizer knowing a fixed set of kinds.
+type staticPluralizer map[schema.GroupVersionKind]string
+
+func (p staticPluralizer) GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	resource, ok := p[gvk]
+	if !ok {
+		return schema.GroupVersionResource{}, fmt.Errorf("no resource for %s", gvk)
+	}
+	return gvk.GroupVersion().WithResource(resource), nil
+}
+
+func TestManifestResources(t *testing.T) {
+	p := staticPluralizer{
+		{Group: "apps", Version: "v1", Kind: "Deployment"}: "deployments",
+	}
+	objs := []manifest.Object{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Source: "app/templates/deployment.yaml"},
+		{APIVersion: "example.io/v1", Kind: "Policy", Name: "default", Namespace: "demo", Source: "app/templates/policy.yaml"},
+	}
+
+	got := manifestResources(objs, p)
+	expected := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Name: "web", Kind: "Deployment", Origin: resources.OriginRendered, Source: "app/templates/deployment.yaml", Template: "templates/deployment.yaml"},
+		{Group: "example.io", Version: "v1", Resource: "policies", Name: "default", Namespace: "demo", Kind: "Policy", Origin: resources.OriginRendered, Source: "app/templates/policy.yaml", Template: "templates/policy.yaml"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestSplitSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		template string
+		subchart string
+	}{
+		{source: "app/templates/deployment.yaml", template: "templates/deployment.yaml"},
+		{source: "app/templates/rbac/binding.yaml", template: "templates/rbac/binding.yaml"},
+		{source: "app/charts/redis/templates/service.yaml", template: "templates/service.yaml", subchart: "redis"},
+		{source: "app/charts/redis/charts/common/templates/secret.yaml", template: "templates/secret.yaml", subchart: "redis/common"},
+		{source: "app/crds/widgets.yaml", template: "crds/widgets.yaml"},
+		{source: "widgets.yaml", template: "widgets.yaml"},
+	}
+
+	for _, tt := range tests {
+		template, subchart := splitSource(tt.source)
+		if template != tt.template || subchart != tt.subchart {
+			t.Errorf("%s: expected (%q, %q), got (%q, %q)", tt.source, tt.template, tt.subchart, template, subchart)
+		}
+	}
+}
+
+func renderedFixture() []resources.Resource {
+	cluster := false
+	rendered := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Name: "web", Kind: "Deployment", Origin: resources.OriginRendered, Source: "app/templates/deployment.yaml", Template: "templates/deployment.yaml"},
+		{Version: "v1", Resource: "services", Namespace: "cache", Name: "redis", Kind: "Service", Origin: resources.OriginRendered, Source: "app/charts/redis/templates/service.yaml", Template: "templates/service.yaml", Subchart: "redis"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "app", Kind: "ClusterRole", Namespaced: &cluster, Origin: resources.OriginRendered, Source: "app/templates/rbac.yaml", Template: "templates/rbac.yaml"},
+	}
+	placeRendered(rendered, "demo")
+	return rendered
+}
+
+func trafficFixture() []resources.Resource {
+	return []resources.Resource{
+		{Version: "v1", Resource: "secrets", Namespace: "demo", Verbs: []string{"list"}, Origin: resources.OriginHelm},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Verbs: []string{"get"}, Origin: resources.OriginLookup},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Subresource: "scale", Verbs: []string{"get"}, Origin: resources.OriginLookup},
+		{Version: "v1", Resource: "configmaps", Namespace: "kube-system", Name: "cluster-info", Verbs: []string{"get"}, Origin: resources.OriginLookup},
+	}
+}
+
+func TestPlaceRendered(t *testing.T) {
+	rendered := renderedFixture()
+	if ns := []string{rendered[0].Namespace, rendered[1].Namespace, rendered[2].Namespace}; !reflect.DeepEqual(ns, []string{"demo", "cache", ""}) {
+		t.Errorf("unexpected namespaces %q", ns)
+	}
+}
+
+func TestAnnotateTemplates(t *testing.T) {
+	traffic := trafficFixture()
+	annotateTemplates(traffic, renderedFixture())
+
+	expected := trafficFixture()
+	expected[1].Source = "app/templates/deployment.yaml"
+	expected[1].Template = "templates/deployment.yaml"
+	if !reflect.DeepEqual(traffic, expected) {
+		t.Errorf("expected:\n%+v\ngot:\n%+v", expected, traffic)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cluster := false
+	got := Merge(trafficFixture(), renderedFixture())
+	expected := []resources.Resource{
+		{Version: "v1", Resource: "secrets", Namespace: "demo", Verbs: []string{"list"}, Origin: resources.OriginHelm, Seen: resources.SeenTraffic},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Verbs: []string{"get"}, Origin: resources.OriginRendered, Seen: resources.SeenBoth, Source: "app/templates/deployment.yaml", Template: "templates/deployment.yaml"},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Subresource: "scale", Verbs: []string{"get"}, Origin: resources.OriginLookup, Seen: resources.SeenTraffic},
+		{Version: "v1", Resource: "configmaps", Namespace: "kube-system", Name: "cluster-info", Verbs: []string{"get"}, Origin: resources.OriginLookup, Seen: resources.SeenTraffic},
+		{Version: "v1", Resource: "services", Namespace: "cache", Name: "redis", Kind: "Service", Origin: resources.OriginRendered, Seen: resources.SeenManifest, Source: "app/charts/redis/templates/service.yaml", Template: "templates/service.yaml", Subchart: "redis"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "app", Kind: "ClusterRole", Namespaced: &cluster, Origin: resources.OriginRendered, Seen: resources.SeenManifest, Source: "app/templates/rbac.yaml", Template: "templates/rbac.yaml"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected:\n%+v\ngot:\n%+v", expected, got)
+	}
+}
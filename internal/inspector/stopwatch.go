@@ -0,0 +1,46 @@
+package inspector
+
+import (
+	"time"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// Names of the timed phases of a request.
+const (
+	phaseComposition           = "composition"
+	phaseValues                = "values"
+	phaseCompositionDefinition = "compositionDefinition"
+	phaseCredentials           = "credentials"
+	phaseInstall               = "install"
+	phaseHooks                 = "hooks"
+	phaseTotal                 = "total"
+)
+
+// stopwatch times the consecutive phases of a request.
+type stopwatch struct {
+	start  time.Time
+	last   time.Time
+	phases []resources.Phase
+}
+
+func newStopwatch() *stopwatch {
+	now := time.Now()
+	return &stopwatch{start: now, last: now}
+}
+
+// lap ends the current phase, recording it as name, and starts the next one.
+func (s *stopwatch) lap(name string) {
+	now := time.Now()
+	s.phases = append(s.phases, resources.Phase{Name: name, DurationMs: milliseconds(now.Sub(s.last))})
+	s.last = now
+}
+
+// stop returns the recorded phases followed by the total.
+func (s *stopwatch) stop() []resources.Phase {
+	return append(s.phases, resources.Phase{Name: phaseTotal, DurationMs: milliseconds(time.Since(s.start))})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,24 @@
+package inspector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStopwatch(t *testing.T) {
+	sw := newStopwatch()
+	sw.lap(phaseComposition)
+	sw.lap(phaseInstall)
+
+	phases := sw.stop()
+	var names []string
+	for _, phase := range phases {
+		names = append(names, phase.Name)
+	}
+	if expected := []string{phaseComposition, phaseInstall, phaseTotal}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected phases %v, got %v", expected, names)
+	}
+	if total := phases[2].DurationMs; total < phases[0].DurationMs+phases[1].DurationMs {
+		t.Errorf("expected the total to cover the phases, got %+v", phases)
+	}
+}
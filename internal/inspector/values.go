@@ -0,0 +1,115 @@
+package inspector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ChartValues are the values the chart of a composition is rendered with,
+// split by source.
+type ChartValues struct {
+	Composition           *unstructured.Unstructured
+	CompositionDefinition *unstructured.Unstructured
+	// Spec are the values of the composition spec the chart receives: its
+	// global values are only those the injection of Global left as they are.
+	Spec map[string]any
+	// Global are the global values the composition-dynamic-controller
+	// injects into Spec, those it adds and those it overrides.
+	Global map[string]any
+	// Values are Spec and Global coalesced with the defaults of the chart and
+	// of its enabled subcharts, as Helm does before rendering.
+	Values map[string]any
+}
+
+// Values computes the values the chart of the composition of req is rendered
+// with, without installing it: the chart is only pulled, or read from the
+// chart cache, for its defaults. Errors are logged to log.
+func Values(ctx context.Context, opts handlers.HandlerOptions, req Request, log *slog.Logger) (*ChartValues, error) {
+	composition, err := getComposition(ctx, opts.DynamicClient, req, log)
+	if err != nil {
+		return nil, err
+	}
+	// The global values of the spec, before the injection overrides them.
+	specGlobal, _, _ := unstructured.NestedMap(composition.Object, "spec", "global")
+	vals, err := buildValues(composition, opts, req, log)
+	if err != nil {
+		return nil, err
+	}
+
+	compositionDefinitionU, compositionDefinition, err := getCompositionDefinition(ctx, opts.DynamicClient, req, log)
+	if err != nil {
+		return nil, err
+	}
+	chartInfo := compositionDefinition.Spec.Chart
+	if chartInfo == nil {
+		return nil, fmt.Errorf("composition definition %s has no chart", compositionDefinitionU.GetName())
+	}
+
+	var username, password string
+	if chartInfo.Credentials != nil {
+		username, password, err = credentials(opts.DynamicClient, compositionDefinition, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+	chart, err := pullChart(ctx, opts, chartInfo, username, password, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// As Helm does on install: subcharts disabled by their condition lose
+	// their defaults, and imported values become defaults of the parent.
+	if err := chartutil.ProcessDependenciesWithMerge(chart, chartutil.Values(vals)); err != nil {
+		log.Error("unable to process chart dependencies",
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+	coalesced, err := chartutil.CoalesceValues(chart, vals)
+	if err != nil {
+		log.Error("unable to coalesce values",
+			slog.Any("err", err),
+		)
+		return nil, err
+	}
+
+	res := &ChartValues{
+		Composition:           composition,
+		CompositionDefinition: compositionDefinitionU,
+		Values:                coalesced,
+	}
+	res.Spec, res.Global = splitGlobal(vals, specGlobal)
+	return res, nil
+}
+
+// splitGlobal splits vals, the values of a spec whose global values were
+// specGlobal before the injection, into the values of the spec and the
+// global values the injection added or changed.
+func splitGlobal(vals, specGlobal map[string]any) (spec, global map[string]any) {
+	spec, global = map[string]any{}, map[string]any{}
+	for k, v := range vals {
+		if k != "global" {
+			spec[k] = v
+		}
+	}
+
+	injected, _ := vals["global"].(map[string]any)
+	kept := map[string]any{}
+	for k, v := range injected {
+		if w, ok := specGlobal[k]; ok && reflect.DeepEqual(v, w) {
+			kept[k] = v
+			continue
+		}
+		global[k] = v
+	}
+	if len(kept) > 0 {
+		spec["global"] = kept
+	}
+	return spec, global
+}
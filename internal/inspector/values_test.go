@@ -0,0 +1,110 @@
+package inspector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/values"
+	coreprovv1 "github.com/krateoplatformops/core-provider/apis/compositiondefinitions/v1alpha1"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestValues(t *testing.T) {
+	redis := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "redis", Version: "1.0.0"},
+		Raw:      []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte("port: 6379\n")}},
+	}
+	demo := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2, Name: "demo", Version: "1.0.0",
+			Dependencies: []*chart.Dependency{{Name: "redis", Version: "1.0.0", Condition: "redis.enabled"}},
+		},
+		Raw: []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte("replicas: 1\nimage: nginx\nredis:\n  enabled: true\n")}},
+	}
+	demo.AddDependency(redis)
+	archive, err := chartutil.Save(demo, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, archive)
+	}))
+	defer srv.Close()
+
+	gvr := schema.GroupVersionResource{Group: "composition.krateo.io", Version: "v1-0-0", Resource: "demos"}
+	cd, err := newCompositionDefinition("demo", "demo-system", &coreprovv1.ChartInfo{Url: srv.URL + "/" + filepath.Base(archive)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{
+		CompositionGVR: gvr,
+		Composition: newComposition(gvr, "Demo", "demo", "demo-ns", map[string]any{
+			"replicas": int64(3),
+			"redis":    map[string]any{"enabled": false},
+			"global":   map[string]any{"compositionName": "spoofed"},
+		}),
+		CompositionDefinition: cd,
+	}
+
+	got, err := Values(context.Background(), handlers.HandlerOptions{KrateoNamespace: "krateo-system"}, req, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSpec := map[string]any{"replicas": int64(3), "redis": map[string]any{"enabled": false}}
+	if !reflect.DeepEqual(got.Spec, expectedSpec) {
+		t.Errorf("expected spec %v, got %v", expectedSpec, got.Spec)
+	}
+	if got.Global["compositionName"] != "demo" || got.Global["krateoNamespace"] != "krateo-system" {
+		t.Errorf("unexpected global values %v", got.Global)
+	}
+	if got.Values["replicas"] != int64(3) || got.Values["image"] != "nginx" {
+		t.Errorf("expected the spec coalesced with the chart defaults, got %v", got.Values)
+	}
+	// The subchart is disabled: its defaults are not coalesced.
+	if !reflect.DeepEqual(got.Values["redis"], map[string]any{"enabled": false}) {
+		t.Errorf("expected the defaults of the disabled subchart to be left out, got %v", got.Values["redis"])
+	}
+}
+
+func TestSplitGlobal(t *testing.T) {
+	vals := map[string]any{
+		"replicas": int64(3),
+		"global": map[string]any{
+			"mine":            "x",
+			"compositionName": "demo",
+			"krateoNamespace": "krateo-system",
+		},
+	}
+	specGlobal := map[string]any{"mine": "x", "compositionName": "spoofed"}
+
+	spec, global := splitGlobal(vals, specGlobal)
+
+	expectedSpec := map[string]any{"replicas": int64(3), "global": map[string]any{"mine": "x"}}
+	if !reflect.DeepEqual(spec, expectedSpec) {
+		t.Errorf("expected spec %v, got %v", expectedSpec, spec)
+	}
+	expectedGlobal := map[string]any{"compositionName": "demo", "krateoNamespace": "krateo-system"}
+	if !reflect.DeepEqual(global, expectedGlobal) {
+		t.Errorf("expected global %v, got %v", expectedGlobal, global)
+	}
+
+	sources := values.Annotate(vals, spec, global).Sources
+	expectedSources := map[string]string{
+		"replicas":               values.SourceComposition,
+		"global.mine":            values.SourceComposition,
+		"global.compositionName": values.SourceGlobal,
+		"global.krateoNamespace": values.SourceGlobal,
+	}
+	if !reflect.DeepEqual(sources, expectedSources) {
+		t.Errorf("expected sources %v, got %v", expectedSources, sources)
+	}
+}
@@ -0,0 +1,95 @@
+// Package manifest parses the manifest of a Helm release: the rendered
+// templates, as a stream of YAML documents each preceded by a
+// "# Source: <chart>/templates/<file>" comment.
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Object is the identity of an object of a release manifest.
+type Object struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	// Source is the path of the template that rendered the object, as
+	// written by Helm in the "# Source:" comment, e.g.
+	// "mychart/charts/redis/templates/service.yaml".
+	Source string
+}
+
+// GroupVersionKind returns the group, version and kind of o.
+func (o Object) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(o.APIVersion, o.Kind)
+}
+
+var (
+	separator     = regexp.MustCompile(`(?m)^---[ \t]*$`)
+	sourceComment = regexp.MustCompile(`(?m)^#\s*Source:\s*(.+?)\s*$`)
+)
+
+// Document is a document of a release manifest.
+type Document struct {
+	// Object is the rendered object.
+	Object map[string]any
+	// Source is the path of the template that rendered the object, see
+	// Object.Source.
+	Source string
+}
+
+// Documents returns the documents of a release manifest, in order. Empty
+// documents and documents without a kind (e.g. only comments) are skipped.
+func Documents(manifest string) ([]Document, error) {
+	var res []Document
+	for i, doc := range separator.Split(manifest, -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+
+		obj := map[string]any{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("unable to parse document %d of the manifest: %w", i, err)
+		}
+		if kind, _ := obj["kind"].(string); kind == "" {
+			continue
+		}
+
+		el := Document{Object: obj}
+		if m := sourceComment.FindStringSubmatch(doc); m != nil {
+			el.Source = m[1]
+		}
+		res = append(res, el)
+	}
+
+	return res, nil
+}
+
+// Parse returns the objects of a release manifest, in order. Empty documents
+// and documents without a kind (e.g. only comments) are skipped. A List is
+// returned as one object: its items are not expanded.
+func Parse(manifest string) ([]Object, error) {
+	docs, err := Documents(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Object
+	for _, doc := range docs {
+		u := unstructured.Unstructured{Object: doc.Object}
+		res = append(res, Object{
+			APIVersion: u.GetAPIVersion(),
+			Kind:       u.GetKind(),
+			Name:       u.GetName(),
+			Namespace:  u.GetNamespace(),
+			Source:     doc.Source,
+		})
+	}
+	return res, nil
+}
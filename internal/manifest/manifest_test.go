@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const releaseManifest = `---
+# Source: focus/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: focus
+---
+# Source: focus/charts/redis/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: focus-redis
+  namespace: cache
+spec:
+  ports:
+  - port: 6379
+---
+# Source: focus/templates/empty.yaml
+# nothing rendered here
+---
+# Source: focus/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: focus
+  labels:
+    app: "focus---web"
+spec:
+  replicas: 1
+`
+
+func TestParse(t *testing.T) {
+	got, err := Parse(releaseManifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Object{
+		{APIVersion: "v1", Kind: "ServiceAccount", Name: "focus", Source: "focus/templates/serviceaccount.yaml"},
+		{APIVersion: "v1", Kind: "Service", Name: "focus-redis", Namespace: "cache", Source: "focus/charts/redis/templates/service.yaml"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "focus", Source: "focus/templates/deployment.yaml"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	if gvk := got[2].GroupVersionKind(); gvk != (schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}) {
+		t.Errorf("unexpected GroupVersionKind %v", gvk)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	got, err := Parse("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no objects, got %+v", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse("---\nkind: [unterminated\n"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	got, err := Documents(releaseManifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(got))
+	}
+	expected := Document{
+		Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]any{"name": "focus-redis", "namespace": "cache"},
+			"spec":       map[string]any{"ports": []any{map[string]any{"port": float64(6379)}}},
+		},
+		Source: "focus/charts/redis/templates/service.yaml",
+	}
+	if !reflect.DeepEqual(got[1], expected) {
+		t.Errorf("expected %+v, got %+v", expected, got[1])
+	}
+}
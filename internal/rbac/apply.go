@@ -0,0 +1,210 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// FieldManager is the field manager the policy is applied with.
+const FieldManager = "chart-inspector"
+
+// Labels and annotations set on the applied objects.
+const (
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// LabelCompositionDefinitionUID is the UID of the CompositionDefinition
+	// the objects were generated for.
+	LabelCompositionDefinitionUID = "chart-inspector.krateo.io/composition-definition-uid"
+	// AnnotationCompositionDefinition identifies the CompositionDefinition
+	// the objects were generated for, as
+	// "<resource>.<version>.<group>/<namespace>/<name>", so that Collect can
+	// look it up.
+	AnnotationCompositionDefinition = "chart-inspector.krateo.io/composition-definition"
+)
+
+// Name returns the default name of the roles and bindings generated for the
+// CompositionDefinition name in namespace. Object names have no colon, so
+// that no two CompositionDefinitions share a name, even across namespaces.
+func Name(namespace, name string) string {
+	return "chart-inspector:" + namespace + ":" + name
+}
+
+// Own labels the objects of p as managed by the service for the
+// CompositionDefinition owner, served as gvr, and sets an owner reference to
+// it on those in its namespace, so that the garbage collector deletes them
+// with it. Kubernetes does not allow owner references across namespaces, nor
+// from cluster-scoped objects to namespaced ones: the other objects are
+// deleted by Collect once owner is gone.
+func (p *Policy) Own(owner *unstructured.Unstructured, gvr schema.GroupVersionResource) {
+	labels := map[string]string{
+		LabelManagedBy:                FieldManager,
+		LabelCompositionDefinitionUID: string(owner.GetUID()),
+	}
+	annotations := map[string]string{
+		AnnotationCompositionDefinition: fmt.Sprintf("%s.%s.%s/%s/%s", gvr.Resource, gvr.Version, gvr.Group, owner.GetNamespace(), owner.GetName()),
+	}
+	ref := metav1.OwnerReference{
+		APIVersion: owner.GetAPIVersion(),
+		Kind:       owner.GetKind(),
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}
+
+	own := func(meta *metav1.ObjectMeta) {
+		if meta.Labels == nil {
+			meta.Labels = map[string]string{}
+		}
+		maps.Copy(meta.Labels, labels)
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		maps.Copy(meta.Annotations, annotations)
+		if meta.Namespace != "" && meta.Namespace == owner.GetNamespace() {
+			meta.OwnerReferences = []metav1.OwnerReference{ref}
+		}
+	}
+
+	if p.ClusterRole != nil {
+		own(&p.ClusterRole.ObjectMeta)
+	}
+	if p.ClusterRoleBinding != nil {
+		own(&p.ClusterRoleBinding.ObjectMeta)
+	}
+	for i := range p.Roles {
+		own(&p.Roles[i].ObjectMeta)
+	}
+	for i := range p.RoleBindings {
+		own(&p.RoleBindings[i].ObjectMeta)
+	}
+}
+
+// Apply creates or updates the objects of p with server-side apply, as
+// FieldManager, roles before the bindings referring to them. It stops at the
+// first object that could not be applied, e.g. on a conflict with the fields
+// of another manager. An existing object is never taken over unless the
+// service applied it for the same CompositionDefinition: Apply fails with a
+// Conflict on one without the LabelManagedBy label of the service, e.g. one
+// created by hand, or applied for another CompositionDefinition.
+func (p Policy) Apply(ctx context.Context, dyn dynamic.Interface) error {
+	for _, obj := range p.Objects() {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		el := &unstructured.Unstructured{Object: u}
+
+		gvr := rbacv1.SchemeGroupVersion.WithResource(resourceOf(el.GetKind()))
+		ri := dyn.Resource(gvr).Namespace(el.GetNamespace())
+
+		existing, err := ri.Get(ctx, el.GetName(), metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return fmt.Errorf("unable to get %s %s: %w", el.GetKind(), el.GetName(), err)
+		default:
+			if existing.GetLabels()[LabelManagedBy] != FieldManager {
+				return apierrors.NewConflict(gvr.GroupResource(), el.GetName(),
+					fmt.Errorf("not managed by %s: it has no %s=%s label", FieldManager, LabelManagedBy, FieldManager))
+			}
+			uid, ok := existing.GetLabels()[LabelCompositionDefinitionUID]
+			if ok && uid != el.GetLabels()[LabelCompositionDefinitionUID] {
+				return apierrors.NewConflict(gvr.GroupResource(), el.GetName(),
+					fmt.Errorf("applied for the CompositionDefinition %s (uid %s)", existing.GetAnnotations()[AnnotationCompositionDefinition], uid))
+			}
+		}
+
+		_, err = ri.Apply(ctx, el.GetName(), el, metav1.ApplyOptions{FieldManager: FieldManager})
+		if err != nil {
+			return fmt.Errorf("unable to apply %s %s: %w", el.GetKind(), el.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// collected are the resources Collect deletes, bindings before the roles they
+// refer to.
+var collected = []string{"rolebindings", "clusterrolebindings", "roles", "clusterroles"}
+
+// Collect deletes the objects applied for CompositionDefinitions that no
+// longer exist, or that were recreated with another UID: those the garbage
+// collector cannot delete because they may not be owned by their
+// CompositionDefinition. It returns the number of deleted objects, and the
+// errors of the objects it could not check or delete.
+func Collect(ctx context.Context, dyn dynamic.Interface) (int, error) {
+	selector := LabelManagedBy + "=" + FieldManager + "," + LabelCompositionDefinitionUID
+	exists := map[string]bool{}
+
+	deleted := 0
+	var errs []error
+	for _, resource := range collected {
+		gvr := rbacv1.SchemeGroupVersion.WithResource(resource)
+		list, err := dyn.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to list %s: %w", resource, err))
+			continue
+		}
+
+		for _, el := range list.Items {
+			ref := el.GetAnnotations()[AnnotationCompositionDefinition]
+			uid := el.GetLabels()[LabelCompositionDefinitionUID]
+			if ref == "" {
+				continue
+			}
+
+			key := ref + "@" + uid
+			alive, ok := exists[key]
+			if !ok {
+				alive, err = ownerExists(ctx, dyn, ref, uid)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("unable to check the owner of %s %s: %w", el.GetKind(), el.GetName(), err))
+					continue
+				}
+				exists[key] = alive
+			}
+			if alive {
+				continue
+			}
+
+			precondition := el.GetUID()
+			err := dyn.Resource(gvr).Namespace(el.GetNamespace()).Delete(ctx, el.GetName(), metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{UID: &precondition},
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("unable to delete %s %s: %w", el.GetKind(), el.GetName(), err))
+				continue
+			}
+			deleted++
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// ownerExists reports whether the CompositionDefinition ref, as written in
+// AnnotationCompositionDefinition, exists with the given uid.
+func ownerExists(ctx context.Context, dyn dynamic.Interface, ref, uid string) (bool, error) {
+	resource, namespacedName, _ := strings.Cut(ref, "/")
+	namespace, name, ok := strings.Cut(namespacedName, "/")
+	gvr, _ := schema.ParseResourceArg(resource)
+	if !ok || gvr == nil || name == "" {
+		return false, fmt.Errorf("invalid %s annotation %q", AnnotationCompositionDefinition, ref)
+	}
+
+	owner, err := dyn.Resource(*gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return string(owner.GetUID()) == uid, nil
+}
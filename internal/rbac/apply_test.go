@@ -0,0 +1,234 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var compositionDefinitionGVR = schema.GroupVersionResource{Group: "core.krateo.io", Version: "v1alpha1", Resource: "compositiondefinitions"}
+
+func compositionDefinition(namespace, name, uid string) *unstructured.Unstructured {
+	res := &unstructured.Unstructured{}
+	res.SetAPIVersion("core.krateo.io/v1alpha1")
+	res.SetKind("CompositionDefinition")
+	res.SetNamespace(namespace)
+	res.SetName(name)
+	res.SetUID(types.UID(uid))
+	return res
+}
+
+func TestApply(t *testing.T) {
+	namespaced, cluster := true, false
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "settings", Verbs: []string{"get"}, Namespaced: &namespaced},
+		{Version: "v1", Resource: "configmaps", Namespace: "other", Name: "settings", Verbs: []string{"get"}, Namespaced: &namespaced},
+		{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions", Name: "widgets.example.io", Verbs: []string{"get"}, Namespaced: &cluster},
+	}
+	policy := Generate(traced, nil, Options{Name: Name("demo", "widgets"), ServiceAccountName: "cdc", ServiceAccountNamespace: "demo"})
+	policy.Own(compositionDefinition("demo", "widgets", "1234"), compositionDefinitionGVR)
+
+	scheme := runtime.NewScheme()
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme)
+	applied := map[string]*unstructured.Unstructured{}
+	dyn.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		if patch.GetPatchType() != types.ApplyPatchType || patch.PatchOptions.FieldManager != FieldManager {
+			t.Errorf("expected an apply patch by %s, got %s by %q", FieldManager, patch.GetPatchType(), patch.PatchOptions.FieldManager)
+		}
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		applied[patch.GetResource().Resource+"/"+patch.GetNamespace()] = u
+		return true, u, nil
+	})
+	if err := policy.Apply(context.Background(), dyn); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{
+		LabelManagedBy:                FieldManager,
+		LabelCompositionDefinitionUID: "1234",
+	}
+	refs := []metav1.OwnerReference{{APIVersion: "core.krateo.io/v1alpha1", Kind: "CompositionDefinition", Name: "widgets", UID: "1234"}}
+	tests := []struct {
+		resource, namespace string
+		owned               bool
+	}{
+		{resource: "clusterroles"},
+		{resource: "clusterrolebindings"},
+		{resource: "roles", namespace: "demo", owned: true},
+		{resource: "rolebindings", namespace: "demo", owned: true},
+		{resource: "roles", namespace: "other"},
+		{resource: "rolebindings", namespace: "other"},
+	}
+	if len(applied) != len(tests) {
+		t.Errorf("expected %d objects applied, got %d", len(tests), len(applied))
+	}
+	for _, tt := range tests {
+		t.Run(tt.resource+"/"+tt.namespace, func(t *testing.T) {
+			got := applied[tt.resource+"/"+tt.namespace]
+			if got == nil {
+				t.Fatal("not applied")
+			}
+			if got.GetName() != "chart-inspector:demo:widgets" {
+				t.Errorf("expected name chart-inspector:demo:widgets, got %s", got.GetName())
+			}
+			if !reflect.DeepEqual(got.GetLabels(), labels) {
+				t.Errorf("expected labels %v, got %v", labels, got.GetLabels())
+			}
+			if ref := got.GetAnnotations()[AnnotationCompositionDefinition]; ref != "compositiondefinitions.v1alpha1.core.krateo.io/demo/widgets" {
+				t.Errorf("unexpected %s annotation %q", AnnotationCompositionDefinition, ref)
+			}
+			var expected []metav1.OwnerReference
+			if tt.owned {
+				expected = refs
+			}
+			if !reflect.DeepEqual(got.GetOwnerReferences(), expected) {
+				t.Errorf("expected owner references %v, got %v", expected, got.GetOwnerReferences())
+			}
+		})
+	}
+}
+
+func TestApplyOwnedByAnother(t *testing.T) {
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "configmaps", Name: "settings", Verbs: []string{"list"}},
+	}
+	policy := Generate(traced, nil, Options{Name: "cdc", ServiceAccountName: "cdc", ServiceAccountNamespace: "demo"})
+	policy.Own(compositionDefinition("demo", "widgets", "1234"), compositionDefinitionGVR)
+
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	existing.SetKind("ClusterRole")
+	existing.SetName("cdc")
+	existing.SetLabels(map[string]string{LabelManagedBy: FieldManager, LabelCompositionDefinitionUID: "5678"})
+	existing.SetAnnotations(map[string]string{AnnotationCompositionDefinition: "compositiondefinitions.v1alpha1.core.krateo.io/other/gadgets"})
+
+	scheme := runtime.NewScheme()
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme, existing)
+	dyn.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		t.Errorf("expected nothing to be applied, got a patch of %s", action.GetResource().Resource)
+		return true, nil, nil
+	})
+
+	err := policy.Apply(context.Background(), dyn)
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "other/gadgets") {
+		t.Errorf("expected the error to name the other owner, got %v", err)
+	}
+}
+
+func TestApplyForeign(t *testing.T) {
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "configmaps", Name: "settings", Verbs: []string{"list"}},
+	}
+	policy := Generate(traced, nil, Options{Name: "cdc", ServiceAccountName: "cdc", ServiceAccountNamespace: "demo"})
+	policy.Own(compositionDefinition("demo", "widgets", "1234"), compositionDefinitionGVR)
+
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	existing.SetKind("ClusterRole")
+	existing.SetName("cdc")
+
+	scheme := runtime.NewScheme()
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme, existing)
+	dyn.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		t.Errorf("expected nothing to be applied, got a patch of %s", action.GetResource().Resource)
+		return true, nil, nil
+	})
+
+	err := policy.Apply(context.Background(), dyn)
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "not managed by "+FieldManager) {
+		t.Errorf("expected the error to tell the object is not managed by the service, got %v", err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	applied := func(kind, namespace, name, owner, uid string) *unstructured.Unstructured {
+		res := &unstructured.Unstructured{}
+		res.SetAPIVersion("rbac.authorization.k8s.io/v1")
+		res.SetKind(kind)
+		res.SetNamespace(namespace)
+		res.SetName(name)
+		res.SetLabels(map[string]string{LabelManagedBy: FieldManager, LabelCompositionDefinitionUID: uid})
+		res.SetAnnotations(map[string]string{AnnotationCompositionDefinition: "compositiondefinitions.v1alpha1.core.krateo.io/" + owner})
+		return res
+	}
+	unmanaged := &unstructured.Unstructured{}
+	unmanaged.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	unmanaged.SetKind("ClusterRole")
+	unmanaged.SetName("admin")
+
+	listKinds := map[schema.GroupVersionResource]string{compositionDefinitionGVR: "CompositionDefinitionList"}
+	for resource, kind := range map[string]string{"roles": "RoleList", "rolebindings": "RoleBindingList", "clusterroles": "ClusterRoleList", "clusterrolebindings": "ClusterRoleBindingList"} {
+		listKinds[rbacv1.SchemeGroupVersion.WithResource(resource)] = kind
+	}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds,
+		compositionDefinition("demo", "widgets", "1234"),
+		compositionDefinition("demo", "recreated", "new"),
+		// Alive.
+		applied("ClusterRole", "", "chart-inspector:demo:widgets", "demo/widgets", "1234"),
+		applied("RoleBinding", "other", "chart-inspector:demo:widgets", "demo/widgets", "1234"),
+		// Deleted, or recreated with another UID.
+		applied("ClusterRole", "", "chart-inspector:demo:gone", "demo/gone", "9999"),
+		applied("ClusterRoleBinding", "", "chart-inspector:demo:gone", "demo/gone", "9999"),
+		applied("Role", "other", "chart-inspector:demo:gone", "demo/gone", "9999"),
+		applied("RoleBinding", "other", "chart-inspector:demo:recreated", "demo/recreated", "old"),
+		unmanaged,
+	)
+
+	deleted, err := Collect(context.Background(), dyn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 4 {
+		t.Errorf("expected 4 objects deleted, got %d", deleted)
+	}
+
+	tests := []struct {
+		resource, namespace, name string
+		kept                      bool
+	}{
+		{resource: "clusterroles", name: "chart-inspector:demo:widgets", kept: true},
+		{resource: "rolebindings", namespace: "other", name: "chart-inspector:demo:widgets", kept: true},
+		{resource: "clusterroles", name: "admin", kept: true},
+		{resource: "clusterroles", name: "chart-inspector:demo:gone"},
+		{resource: "clusterrolebindings", name: "chart-inspector:demo:gone"},
+		{resource: "roles", namespace: "other", name: "chart-inspector:demo:gone"},
+		{resource: "rolebindings", namespace: "other", name: "chart-inspector:demo:recreated"},
+	}
+	for _, tt := range tests {
+		_, err := dyn.Resource(rbacv1.SchemeGroupVersion.WithResource(tt.resource)).Namespace(tt.namespace).Get(context.Background(), tt.name, metav1.GetOptions{})
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s %s/%s: expected kept=%t, got %v", tt.resource, tt.namespace, tt.name, tt.kept, err)
+		}
+	}
+}
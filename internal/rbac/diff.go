@@ -0,0 +1,281 @@
+package rbac
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+// BindingRef identifies a RoleBinding or a ClusterRoleBinding and the role
+// it refers to.
+type BindingRef struct {
+	Kind      string         `json:"kind"`
+	Namespace string         `json:"namespace,omitempty"`
+	Name      string         `json:"name"`
+	RoleRef   rbacv1.RoleRef `json:"roleRef"`
+	// Group is the group of the ServiceAccount the binding names.
+	Group string `json:"group,omitempty"`
+}
+
+// Binding is a binding of a ServiceAccount and the rules of the role it
+// refers to, granted in the namespace of the binding, or on the whole
+// cluster for a ClusterRoleBinding.
+type Binding struct {
+	BindingRef
+	Rules []rbacv1.PolicyRule
+}
+
+// OverGrant is a bound rule granting more than the chart needs.
+type OverGrant struct {
+	Binding BindingRef        `json:"binding"`
+	Rule    rbacv1.PolicyRule `json:"rule"`
+	// Unused is set when the rule allows none of the needed calls.
+	Unused bool `json:"unused,omitempty"`
+	// ExtraAPIGroups, ExtraResources and ExtraVerbs are the entries of a
+	// used rule no needed call it allows matches. Wildcards are always
+	// reported: they grant whatever the API server serves.
+	ExtraAPIGroups []string `json:"extraAPIGroups,omitempty"`
+	ExtraResources []string `json:"extraResources,omitempty"`
+	ExtraVerbs     []string `json:"extraVerbs,omitempty"`
+}
+
+// Diff compares the RBAC a chart needs with the RBAC bound to a
+// ServiceAccount.
+type Diff struct {
+	// Missing are the rules to grant for the bound RBAC to allow all the
+	// needed calls.
+	Missing []Grant `json:"missing"`
+	// OverGranted are the bound rules granting more than needed.
+	OverGranted []OverGrant `json:"overGranted"`
+	// UnusedBindings are the bindings none of whose rules allows a needed
+	// call.
+	UnusedBindings []BindingRef `json:"unusedBindings"`
+	// GroupBindings are the bindings naming a group of the ServiceAccount.
+	GroupBindings []BindingRef `json:"groupBindings"`
+	// Truncated is set when the tracer reached its limits.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Compare diffs the grants needed to make the traced calls and to install
+// the rendered objects, as Generate grants them, with bindings. The missing
+// rules are built with strategy, see Strategies. The rules bound to a group
+// allow the needed calls as the others do, but their bindings are only listed
+// in GroupBindings: they are not specific to the ServiceAccount, so they are
+// neither over-granted nor unused for it.
+func Compare(traced, rendered []resources.Resource, bindings []Binding, strategy string) Diff {
+	var all []Attributes
+	bound := grantsOf(bindings)
+	missing := map[string][]grant{}
+	for ns, grants := range needs(traced, rendered) {
+		for _, g := range grants {
+			attrs := g.attributes(ns)
+			all = append(all, attrs)
+			if !Allows(bound, attrs) {
+				missing[ns] = append(missing[ns], g)
+			}
+		}
+	}
+
+	res := Diff{
+		Missing:        []Grant{},
+		OverGranted:    []OverGrant{},
+		UnusedBindings: []BindingRef{},
+		GroupBindings:  []BindingRef{},
+	}
+	for _, ns := range slices.Sorted(maps.Keys(missing)) {
+		res.Missing = append(res.Missing, Grant{
+			Namespace: ns,
+			Rules:     minimize(missing[ns], strategy, 0).rules,
+		})
+	}
+
+	for _, b := range bindings {
+		if b.Group != "" {
+			res.GroupBindings = append(res.GroupBindings, b.BindingRef)
+			continue
+		}
+		used := false
+		for _, rule := range b.Rules {
+			var allowed []Attributes
+			for _, attrs := range all {
+				if (b.Namespace == "" || b.Namespace == attrs.Namespace) && ruleAllows(rule, attrs) {
+					allowed = append(allowed, attrs)
+				}
+			}
+			if len(allowed) == 0 {
+				res.OverGranted = append(res.OverGranted, OverGrant{Binding: b.BindingRef, Rule: rule, Unused: true})
+				continue
+			}
+			used = true
+
+			over := OverGrant{
+				Binding: b.BindingRef,
+				Rule:    rule,
+				ExtraAPIGroups: extra(rule.APIGroups, allowed, func(el string, attrs Attributes) bool {
+					return el == attrs.Group
+				}),
+				ExtraResources: extra(rule.Resources, allowed, func(el string, attrs Attributes) bool {
+					if attrs.Subresource != "" {
+						return el == attrs.Resource+"/"+attrs.Subresource
+					}
+					return el == attrs.Resource
+				}),
+				ExtraVerbs: extra(rule.Verbs, allowed, func(el string, attrs Attributes) bool {
+					return el == attrs.Verb
+				}),
+			}
+			if len(over.ExtraAPIGroups) > 0 || len(over.ExtraResources) > 0 || len(over.ExtraVerbs) > 0 {
+				res.OverGranted = append(res.OverGranted, over)
+			}
+		}
+		if !used {
+			res.UnusedBindings = append(res.UnusedBindings, b.BindingRef)
+		}
+	}
+
+	return res
+}
+
+// grantsOf returns the rules of bindings as grants.
+func grantsOf(bindings []Binding) []Grant {
+	res := make([]Grant, 0, len(bindings))
+	for _, b := range bindings {
+		res = append(res, Grant{Namespace: b.Namespace, Rules: b.Rules})
+	}
+	return res
+}
+
+// extra returns the entries of a rule that are wildcards or match none of
+// allowed.
+func extra(entries []string, allowed []Attributes, match func(el string, attrs Attributes) bool) []string {
+	var res []string
+	for _, el := range entries {
+		if el == "*" || strings.HasPrefix(el, "*/") ||
+			!slices.ContainsFunc(allowed, func(attrs Attributes) bool { return match(el, attrs) }) {
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+// BoundTo returns the RoleBindings and ClusterRoleBindings of the cluster
+// naming the ServiceAccount namespace/name as a subject, either as a
+// ServiceAccount or as its user, or naming one of groups, the groups the API
+// server authenticates it with, with the rules of the roles they refer to.
+// The Group of a binding is set when it names the ServiceAccount only through
+// one of groups. A binding referring to a missing role grants no rules.
+func BoundTo(ctx context.Context, dyn dynamic.Interface, namespace, name string, groups []string) ([]Binding, error) {
+	var res []Binding
+	for _, kind := range []string{"ClusterRoleBinding", "RoleBinding"} {
+		gvr := rbacv1.SchemeGroupVersion.WithResource(resourceOf(kind))
+		li, err := dyn.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list %s: %w", gvr.Resource, err)
+		}
+
+		for _, el := range li.Items {
+			// ClusterRoleBindings and RoleBindings share the fields used here.
+			binding := rbacv1.RoleBinding{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(el.Object, &binding); err != nil {
+				return nil, fmt.Errorf("unable to convert %s %s: %w", kind, el.GetName(), err)
+			}
+			group := ""
+			if !slices.ContainsFunc(binding.Subjects, func(s rbacv1.Subject) bool {
+				return names(s, binding.Namespace, namespace, name)
+			}) {
+				i := slices.IndexFunc(binding.Subjects, func(s rbacv1.Subject) bool {
+					return s.Kind == rbacv1.GroupKind && slices.Contains(groups, s.Name)
+				})
+				if i < 0 {
+					continue
+				}
+				group = binding.Subjects[i].Name
+			}
+
+			rules, err := rulesOf(ctx, dyn, binding.Namespace, binding.RoleRef)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, Binding{
+				BindingRef: BindingRef{
+					Kind:      kind,
+					Namespace: binding.Namespace,
+					Name:      binding.Name,
+					RoleRef:   binding.RoleRef,
+					Group:     group,
+				},
+				Rules: rules,
+			})
+		}
+	}
+
+	slices.SortStableFunc(res, func(a, b Binding) int {
+		return cmp.Or(
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return res, nil
+}
+
+// names tells whether the subject s of a binding in bindingNamespace names
+// the ServiceAccount namespace/name.
+func names(s rbacv1.Subject, bindingNamespace, namespace, name string) bool {
+	switch s.Kind {
+	case rbacv1.ServiceAccountKind:
+		ns := s.Namespace
+		if ns == "" {
+			ns = bindingNamespace
+		}
+		return ns == namespace && s.Name == name
+	case rbacv1.UserKind:
+		return s.Name == "system:serviceaccount:"+namespace+":"+name
+	}
+	return false
+}
+
+// rulesOf returns the rules of the role ref refers to from a binding in
+// namespace.
+func rulesOf(ctx context.Context, dyn dynamic.Interface, namespace string, ref rbacv1.RoleRef) ([]rbacv1.PolicyRule, error) {
+	if ref.Kind == "ClusterRole" {
+		namespace = ""
+	}
+	gvr := rbacv1.SchemeGroupVersion.WithResource(resourceOf(ref.Kind))
+	u, err := dyn.Resource(gvr).Namespace(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %s %s: %w", ref.Kind, ref.Name, err)
+	}
+
+	role := rbacv1.ClusterRole{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &role); err != nil {
+		return nil, fmt.Errorf("unable to convert %s %s: %w", ref.Kind, ref.Name, err)
+	}
+	return role.Rules, nil
+}
+
+// resourceOf returns the resource of an RBAC kind.
+func resourceOf(kind string) string {
+	switch kind {
+	case "Role":
+		return "roles"
+	case "ClusterRole":
+		return "clusterroles"
+	case "RoleBinding":
+		return "rolebindings"
+	default:
+		return "clusterrolebindings"
+	}
+}
@@ -0,0 +1,138 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestCompare(t *testing.T) {
+	namespaced := true
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "settings", Verbs: []string{"get"}, Namespaced: &namespaced, Origin: resources.OriginLookup},
+	}
+	rendered := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Namespaced: &namespaced, Origin: resources.OriginRendered},
+	}
+
+	wide := BindingRef{Kind: "RoleBinding", Namespace: "demo", Name: "wide", RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "wide"}}
+	other := BindingRef{Kind: "RoleBinding", Namespace: "other", Name: "other", RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "other"}}
+	group := BindingRef{Kind: "ClusterRoleBinding", Name: "deleters", RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "deleter"}, Group: "system:serviceaccounts"}
+	bindings := []Binding{
+		{BindingRef: wide, Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"configmaps", "secrets"}, Verbs: []string{"*"}},
+			{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "update"}},
+		}},
+		{BindingRef: other, Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create"}},
+		}},
+		{BindingRef: group, Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"delete"}},
+		}},
+	}
+
+	got := Compare(traced, rendered, bindings, StrategyResource)
+
+	missing := []Grant{{Namespace: "demo", Rules: []rbacv1.PolicyRule{
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "patch"}},
+	}}}
+	if !reflect.DeepEqual(got.Missing, missing) {
+		t.Errorf("expected missing %+v, got %+v", missing, got.Missing)
+	}
+
+	overGranted := []OverGrant{
+		{Binding: wide, Rule: bindings[0].Rules[0], ExtraResources: []string{"secrets"}, ExtraVerbs: []string{"*"}},
+		{Binding: wide, Rule: bindings[0].Rules[1], Unused: true},
+		{Binding: other, Rule: bindings[1].Rules[0], Unused: true},
+	}
+	if !reflect.DeepEqual(got.OverGranted, overGranted) {
+		t.Errorf("expected over-granted %+v, got %+v", overGranted, got.OverGranted)
+	}
+
+	if !reflect.DeepEqual(got.UnusedBindings, []BindingRef{other}) {
+		t.Errorf("expected unused bindings [%+v], got %+v", other, got.UnusedBindings)
+	}
+
+	if !reflect.DeepEqual(got.GroupBindings, []BindingRef{group}) {
+		t.Errorf("expected group bindings [%+v], got %+v", group, got.GroupBindings)
+	}
+}
+
+func TestBoundTo(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Name: "cdc", Namespace: "krateo-system"}
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme,
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}, Rules: rules},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "cdc", Namespace: "demo"}, Rules: rules},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: "system:serviceaccount:krateo-system:cdc"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "everyone"},
+			Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "cdc", Namespace: "demo"},
+			Subjects:   []rbacv1.Subject{sa},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "cdc"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "dangling", Namespace: "demo"},
+			Subjects:   []rbacv1.Subject{sa},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "gone"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "krateo-system"},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "cdc"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "namespace", Namespace: "demo"},
+			Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts:krateo-system"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "cdc"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "demo"},
+			Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts:demo"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "cdc"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "someone-else", Namespace: "demo"},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "cdc", Namespace: "demo"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "cdc"},
+		},
+	)
+
+	groups := []string{"system:serviceaccounts", "system:serviceaccounts:krateo-system", "system:authenticated"}
+	got, err := BoundTo(context.Background(), dyn, "krateo-system", "cdc", groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Binding{
+		{BindingRef: BindingRef{Kind: "ClusterRoleBinding", Name: "everyone", RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}, Group: "system:serviceaccounts"}, Rules: rules},
+		{BindingRef: BindingRef{Kind: "ClusterRoleBinding", Name: "view", RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}}, Rules: rules},
+		{BindingRef: BindingRef{Kind: "RoleBinding", Namespace: "demo", Name: "cdc", RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "cdc"}}, Rules: rules},
+		{BindingRef: BindingRef{Kind: "RoleBinding", Namespace: "demo", Name: "dangling", RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "gone"}}},
+		{BindingRef: BindingRef{Kind: "RoleBinding", Namespace: "demo", Name: "namespace", RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "cdc"}, Group: "system:serviceaccounts:krateo-system"}, Rules: rules},
+		{BindingRef: BindingRef{Kind: "RoleBinding", Namespace: "krateo-system", Name: "local", RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}}, Rules: rules},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
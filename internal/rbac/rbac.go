@@ -0,0 +1,267 @@
+// Package rbac turns the resources touched by the dry-run of a chart into the
+// Roles, ClusterRoles and bindings a ServiceAccount needs to install it.
+package rbac
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderedVerbs are the verbs granted on the objects a chart renders: a
+// dry-run only looks them up, while installing, upgrading and uninstalling
+// the release creates, updates, patches and deletes them.
+var RenderedVerbs = []string{"create", "delete", "get", "patch", "update"}
+
+// StorageVerbs are the verbs granted on the Secrets Helm stores its releases
+// in: a dry-run only lists them, while a real install also writes them.
+var StorageVerbs = []string{"create", "delete", "get", "list", "update"}
+
+// Options configures the generated policy.
+type Options struct {
+	// Name is the name of the generated roles and bindings.
+	Name string
+	// ServiceAccountName and ServiceAccountNamespace identify the
+	// ServiceAccount the roles are bound to.
+	ServiceAccountName      string
+	ServiceAccountNamespace string
+	// Strategy is the strategy the rules are built with, StrategyResource
+	// when empty.
+	Strategy string
+	// MaxRules is the maximum number of rules of a role, 0 for no limit. A
+	// role that needs more with Strategy falls back to the next, coarser,
+	// strategy.
+	MaxRules int
+}
+
+// Policy is the RBAC granting a ServiceAccount the calls made to install a
+// chart: a Role and a RoleBinding per namespace, and a ClusterRole and its
+// binding for cluster-scoped resources and cluster-wide calls.
+type Policy struct {
+	Roles              []rbacv1.Role
+	RoleBindings       []rbacv1.RoleBinding
+	ClusterRole        *rbacv1.ClusterRole
+	ClusterRoleBinding *rbacv1.ClusterRoleBinding
+}
+
+// grant is a verb needed on an object of a resource, or on the whole
+// resource when name is empty.
+type grant struct {
+	group, resource, name, verb string
+}
+
+// unnamedVerbs are the verbs a rule cannot restrict to resourceNames: the
+// name of the object is not known when they are authorized.
+var unnamedVerbs = []string{"create", "deletecollection", "list", "watch"}
+
+// Generate builds the policy granting the verbs of the traced calls and
+// RenderedVerbs on the rendered objects. Calls on a namespaced resource in a
+// namespace go to the Role of that namespace; calls on a cluster-scoped
+// resource, and cluster-wide calls on a namespaced one, go to the
+// ClusterRole. Subresources are granted as "resource/subresource". The rules
+// of each role are built with the strategy of opts, see Strategies.
+func Generate(traced, rendered []resources.Resource, opts Options) Policy {
+	roles := map[string]role{}
+	for ns, grants := range needs(traced, rendered) {
+		roles[ns] = minimize(grants, opts.Strategy, opts.MaxRules)
+	}
+
+	return newPolicy(roles, opts)
+}
+
+// needs returns the grants needed to make the traced calls and to install
+// the rendered objects, keyed by the namespace of the role they go to (the
+// empty one standing for the cluster).
+func needs(traced, rendered []resources.Resource) map[string][]grant {
+	scopes := map[string]map[grant]bool{}
+	add := func(el resources.Resource, verbs []string) {
+		ns := ""
+		if el.Namespace != "" && (el.Namespaced == nil || *el.Namespaced) {
+			ns = el.Namespace
+		}
+		resource := el.Resource
+		if el.Subresource != "" {
+			resource += "/" + el.Subresource
+		}
+		if scopes[ns] == nil {
+			scopes[ns] = map[grant]bool{}
+		}
+		for _, verb := range verbs {
+			g := grant{group: el.Group, resource: resource, name: el.Name, verb: verb}
+			if slices.Contains(unnamedVerbs, verb) {
+				g.name = ""
+			}
+			scopes[ns][g] = true
+		}
+	}
+
+	for _, el := range traced {
+		add(el, el.Verbs)
+		if el.Origin == resources.OriginHelm && el.Group == "" && el.Resource == "secrets" {
+			add(el, StorageVerbs)
+		}
+	}
+	for _, el := range rendered {
+		add(el, RenderedVerbs)
+	}
+
+	res := map[string][]grant{}
+	for ns, grants := range scopes {
+		res[ns] = slices.SortedFunc(maps.Keys(grants), compareGrants)
+	}
+	return res
+}
+
+func compareGrants(a, b grant) int {
+	return cmp.Or(
+		cmp.Compare(a.group, b.group),
+		cmp.Compare(a.resource, b.resource),
+		cmp.Compare(a.name, b.name),
+		cmp.Compare(a.verb, b.verb),
+	)
+}
+
+// attributes returns the attributes a request needing g in the namespace ns
+// is authorized on.
+func (g grant) attributes(ns string) Attributes {
+	resource, subresource, _ := strings.Cut(g.resource, "/")
+	return authorized(Attributes{
+		Verb:        g.verb,
+		Group:       g.group,
+		Resource:    resource,
+		Subresource: subresource,
+		Namespace:   ns,
+		Name:        g.name,
+	})
+}
+
+// newPolicy builds the roles, keyed by namespace (the empty one standing for
+// the cluster), and binds them to the ServiceAccount of opts.
+func newPolicy(roles map[string]role, opts Options) Policy {
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      opts.ServiceAccountName,
+		Namespace: opts.ServiceAccountNamespace,
+	}}
+
+	res := Policy{}
+	for _, ns := range slices.Sorted(maps.Keys(roles)) {
+		meta := metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: ns,
+			Annotations: map[string]string{
+				AnnotationStrategy:    roles[ns].strategy,
+				AnnotationExplanation: roles[ns].explanation,
+			},
+		}
+
+		if ns == "" {
+			res.ClusterRole = &rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+				ObjectMeta: meta,
+				Rules:      roles[ns].rules,
+			}
+			res.ClusterRoleBinding = &rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: opts.Name},
+				Subjects:   subjects,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: opts.Name},
+			}
+			continue
+		}
+
+		res.Roles = append(res.Roles, rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: meta,
+			Rules:      roles[ns].rules,
+		})
+		res.RoleBindings = append(res.RoleBindings, rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: ns},
+			Subjects:   subjects,
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: opts.Name},
+		})
+	}
+
+	return res
+}
+
+// Objects returns the objects of p in the order they are applied: the roles
+// before the bindings referring to them.
+func (p Policy) Objects() []runtime.Object {
+	res := []runtime.Object{}
+	if p.ClusterRole != nil {
+		res = append(res, p.ClusterRole)
+	}
+	for i := range p.Roles {
+		res = append(res, &p.Roles[i])
+	}
+	if p.ClusterRoleBinding != nil {
+		res = append(res, p.ClusterRoleBinding)
+	}
+	for i := range p.RoleBindings {
+		res = append(res, &p.RoleBindings[i])
+	}
+	return res
+}
+
+// List returns the objects of p as a v1 List, ready to be applied with
+// kubectl.
+func (p Policy) List() (map[string]any, error) {
+	items := []any{}
+	for _, obj := range p.Objects() {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, u)
+	}
+
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	}, nil
+}
+
+// YAML returns the objects of p as a multi-document YAML stream.
+func (p Policy) YAML() ([]byte, error) {
+	var res []byte
+	for _, obj := range p.Objects() {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		data, err := yaml.Marshal(u)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, "---\n"...)
+		res = append(res, data...)
+	}
+	return res, nil
+}
+
+// toUnstructured converts obj to its JSON representation, without the empty
+// creation timestamp the typed objects carry.
+func toUnstructured(obj runtime.Object) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := map[string]any{}
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, err
+	}
+	unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+	return u, nil
+}
@@ -0,0 +1,119 @@
+package rbac
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestGenerate(t *testing.T) {
+	namespaced, cluster := true, false
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "secrets", Namespace: "demo", Verbs: []string{"list"}, Origin: resources.OriginHelm},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Verbs: []string{"get"}, Namespaced: &namespaced, Origin: resources.OriginLookup},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Subresource: "scale", Verbs: []string{"get"}, Namespaced: &namespaced, Origin: resources.OriginLookup},
+		{Version: "v1", Resource: "configmaps", Namespace: "kube-system", Name: "cluster-info", Verbs: []string{"get"}, Namespaced: &namespaced, Origin: resources.OriginLookup},
+		{Version: "v1", Resource: "configmaps", Verbs: []string{"list"}, Namespaced: &namespaced, Origin: resources.OriginLookup},
+		{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions", Name: "widgets.example.io", Verbs: []string{"get"}, Namespaced: &cluster, Origin: resources.OriginDiscovery},
+	}
+	rendered := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Namespaced: &namespaced, Origin: resources.OriginRendered},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "app", Namespaced: &cluster, Origin: resources.OriginRendered},
+	}
+
+	got := Generate(traced, rendered, Options{Name: "cdc", ServiceAccountName: "cdc-sa", ServiceAccountNamespace: "krateo-system"})
+
+	clusterRules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}, Verbs: []string{"create", "delete", "get", "patch", "update"}},
+	}
+	if got.ClusterRole == nil || !reflect.DeepEqual(got.ClusterRole.Rules, clusterRules) {
+		t.Errorf("expected cluster rules %+v, got %+v", clusterRules, got.ClusterRole)
+	}
+
+	if len(got.Roles) != 2 || got.Roles[0].Namespace != "demo" || got.Roles[1].Namespace != "kube-system" {
+		t.Fatalf("expected roles in demo and kube-system, got %+v", got.Roles)
+	}
+	demoRules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"create", "delete", "get", "list", "update"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "delete", "get", "patch", "update"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}, Verbs: []string{"get"}},
+	}
+	if !reflect.DeepEqual(got.Roles[0].Rules, demoRules) {
+		t.Errorf("expected demo rules %+v, got %+v", demoRules, got.Roles[0].Rules)
+	}
+
+	subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: "cdc-sa", Namespace: "krateo-system"}}
+	for _, el := range got.RoleBindings {
+		if el.Name != "cdc" || !reflect.DeepEqual(el.Subjects, subjects) || el.RoleRef != (rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "cdc"}) {
+			t.Errorf("unexpected role binding %+v", el)
+		}
+	}
+	if crb := got.ClusterRoleBinding; crb == nil || crb.RoleRef.Kind != "ClusterRole" || !reflect.DeepEqual(crb.Subjects, subjects) {
+		t.Errorf("unexpected cluster role binding %+v", crb)
+	}
+}
+
+func TestGenerateWithoutClusterRules(t *testing.T) {
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "settings", Verbs: []string{"get"}},
+	}
+
+	got := Generate(traced, nil, Options{Name: "cdc", ServiceAccountName: "cdc", ServiceAccountNamespace: "demo"})
+	if got.ClusterRole != nil || got.ClusterRoleBinding != nil {
+		t.Errorf("expected no cluster role, got %+v", got.ClusterRole)
+	}
+	if len(got.Roles) != 1 || len(got.RoleBindings) != 1 {
+		t.Errorf("expected a role and its binding, got %+v", got)
+	}
+}
+
+func TestPolicyOutput(t *testing.T) {
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "namespaces", Name: "demo", Verbs: []string{"get"}},
+		{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "settings", Verbs: []string{"get"}},
+	}
+	policy := Generate(traced, nil, Options{Name: "cdc", ServiceAccountName: "cdc", ServiceAccountNamespace: "demo"})
+
+	data, err := policy.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml := string(data)
+	if docs := strings.Count(yaml, "---\n"); docs != 4 {
+		t.Errorf("expected 4 documents, got %d:\n%s", docs, yaml)
+	}
+	if strings.Contains(yaml, "creationTimestamp") {
+		t.Errorf("expected no creation timestamp, got:\n%s", yaml)
+	}
+
+	list, err := policy.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := struct {
+		Kind  string `json:"kind"`
+		Items []struct {
+			Kind string `json:"kind"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, el := range decoded.Items {
+		kinds = append(kinds, el.Kind)
+	}
+	if expected := []string{"ClusterRole", "Role", "ClusterRoleBinding", "RoleBinding"}; decoded.Kind != "List" || !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("expected a List of %v, got %s of %v", expected, decoded.Kind, kinds)
+	}
+}
@@ -0,0 +1,215 @@
+package rbac
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Strategies to build the rules of a role with, from the finest to the
+// coarsest.
+const (
+	// StrategyExact grants each object by name, through resourceNames.
+	StrategyExact = "exact"
+	// StrategyResource grants each resource the verbs needed on any of its
+	// objects.
+	StrategyResource = "resource"
+	// StrategyGroup grants all the resources of an API group the verbs needed
+	// on any of them.
+	StrategyGroup = "group"
+)
+
+// Strategies lists the strategies in the order a role falls back to them
+// when it needs more rules than allowed.
+var Strategies = []string{StrategyExact, StrategyResource, StrategyGroup}
+
+// Annotations set on the generated roles to explain how their rules were
+// built.
+const (
+	AnnotationStrategy    = "chart-inspector.krateo.io/strategy"
+	AnnotationExplanation = "chart-inspector.krateo.io/explanation"
+)
+
+// explanations tell the trade-off of each strategy.
+var explanations = map[string]string{
+	StrategyExact: "Each object is granted by name through resourceNames, the least privilege. " +
+		"The create, list, watch and deletecollection verbs cannot be restricted by name and are granted on the whole resource.",
+	StrategyResource: "Each resource is granted the verbs needed on any of its objects, on all of its objects: " +
+		"objects the chart does not touch are granted too.",
+	StrategyGroup: "The resources of each API group share one rule with the verbs needed on any of them: " +
+		"a verb needed on one resource is granted on all the resources of its group.",
+}
+
+// role is the rules of a role and how they were built.
+type role struct {
+	rules       []rbacv1.PolicyRule
+	strategy    string
+	explanation string
+}
+
+// minimize builds the rules granting grants with strategy, falling back to
+// the coarser strategies while they are more than maxRules (0 for no limit).
+func minimize(grants []grant, strategy string, maxRules int) role {
+	if strategy == "" {
+		strategy = StrategyResource
+	}
+
+	var fallbacks []string
+	i := slices.Index(Strategies, strategy)
+	rules := buildRules(grants, strategy)
+	for maxRules > 0 && len(rules) > maxRules && i+1 < len(Strategies) {
+		fallbacks = append(fallbacks, fmt.Sprintf("the %s strategy needs %d rules, more than the maximum of %d", Strategies[i], len(rules), maxRules))
+		i++
+		rules = buildRules(grants, Strategies[i])
+	}
+
+	res := role{rules: rules, strategy: Strategies[i], explanation: explanations[Strategies[i]]}
+	if len(fallbacks) > 0 {
+		res.explanation = fmt.Sprintf("Fell back to the %s strategy: %s. %s", res.strategy, strings.Join(fallbacks, ", "), res.explanation)
+	}
+	if maxRules > 0 && len(rules) > maxRules {
+		res.explanation += fmt.Sprintf(" The role still needs %d rules, more than the maximum of %d.", len(rules), maxRules)
+	}
+	return res
+}
+
+// buildRules builds the rules granting grants with strategy, sorted by API
+// group and resource.
+func buildRules(grants []grant, strategy string) []rbacv1.PolicyRule {
+	var res []rbacv1.PolicyRule
+	switch strategy {
+	case StrategyExact:
+		res = exactRules(grants)
+	case StrategyGroup:
+		res = groupRules(grants)
+	default:
+		res = resourceRules(grants)
+	}
+
+	slices.SortStableFunc(res, func(a, b rbacv1.PolicyRule) int {
+		return cmp.Or(
+			cmp.Compare(a.APIGroups[0], b.APIGroups[0]),
+			cmp.Compare(a.Resources[0], b.Resources[0]),
+		)
+	})
+	return res
+}
+
+type groupResource struct {
+	group, resource string
+}
+
+// resourceRules grants each resource the union of the verbs of its grants.
+func resourceRules(grants []grant) []rbacv1.PolicyRule {
+	verbs := map[groupResource]map[string]bool{}
+	for _, g := range grants {
+		k := groupResource{g.group, g.resource}
+		if verbs[k] == nil {
+			verbs[k] = map[string]bool{}
+		}
+		verbs[k][g.verb] = true
+	}
+
+	res := []rbacv1.PolicyRule{}
+	for k, set := range verbs {
+		res = append(res, rbacv1.PolicyRule{
+			APIGroups: []string{k.group},
+			Resources: []string{k.resource},
+			Verbs:     slices.Sorted(maps.Keys(set)),
+		})
+	}
+	return res
+}
+
+// groupRules grants all the resources of each API group the union of the
+// verbs of their grants.
+func groupRules(grants []grant) []rbacv1.PolicyRule {
+	resources := map[string]map[string]bool{}
+	verbs := map[string]map[string]bool{}
+	for _, g := range grants {
+		if resources[g.group] == nil {
+			resources[g.group] = map[string]bool{}
+			verbs[g.group] = map[string]bool{}
+		}
+		resources[g.group][g.resource] = true
+		verbs[g.group][g.verb] = true
+	}
+
+	res := []rbacv1.PolicyRule{}
+	for group := range resources {
+		res = append(res, rbacv1.PolicyRule{
+			APIGroups: []string{group},
+			Resources: slices.Sorted(maps.Keys(resources[group])),
+			Verbs:     slices.Sorted(maps.Keys(verbs[group])),
+		})
+	}
+	return res
+}
+
+// exactRules grants each resource the verbs of its unnamed grants, and its
+// objects the verbs of their grants by name. The objects needing the same
+// verbs share a rule; a verb granted on the whole resource is not granted
+// again by name.
+func exactRules(grants []grant) []rbacv1.PolicyRule {
+	unnamed := map[groupResource]map[string]bool{}
+	named := map[groupResource]map[string]map[string]bool{}
+	for _, g := range grants {
+		k := groupResource{g.group, g.resource}
+		if g.name == "" {
+			if unnamed[k] == nil {
+				unnamed[k] = map[string]bool{}
+			}
+			unnamed[k][g.verb] = true
+			continue
+		}
+		if named[k] == nil {
+			named[k] = map[string]map[string]bool{}
+		}
+		if named[k][g.name] == nil {
+			named[k][g.name] = map[string]bool{}
+		}
+		named[k][g.name][g.verb] = true
+	}
+
+	res := []rbacv1.PolicyRule{}
+	for k, set := range unnamed {
+		res = append(res, rbacv1.PolicyRule{
+			APIGroups: []string{k.group},
+			Resources: []string{k.resource},
+			Verbs:     slices.Sorted(maps.Keys(set)),
+		})
+	}
+
+	for k, objects := range named {
+		// Objects needing the same verbs, keyed by the joined verbs.
+		names := map[string][]string{}
+		for name, set := range objects {
+			var verbs []string
+			for verb := range set {
+				if !unnamed[k][verb] {
+					verbs = append(verbs, verb)
+				}
+			}
+			if len(verbs) == 0 {
+				continue
+			}
+			slices.Sort(verbs)
+			key := strings.Join(verbs, ",")
+			names[key] = append(names[key], name)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(names)) {
+			res = append(res, rbacv1.PolicyRule{
+				APIGroups:     []string{k.group},
+				Resources:     []string{k.resource},
+				ResourceNames: slices.Sorted(slices.Values(names[key])),
+				Verbs:         strings.Split(key, ","),
+			})
+		}
+	}
+	return res
+}
@@ -0,0 +1,150 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func grantsFixture() []grant {
+	return []grant{
+		{resource: "configmaps", name: "a", verb: "get"},
+		{resource: "configmaps", name: "b", verb: "get"},
+		{resource: "configmaps", name: "b", verb: "update"},
+		{resource: "configmaps", name: "c", verb: "list"},
+		{resource: "configmaps", verb: "list"},
+		{resource: "secrets", name: "s", verb: "get"},
+		{group: "apps", resource: "deployments", name: "web", verb: "get"},
+		{group: "apps", resource: "deployments", verb: "create"},
+		{group: "apps", resource: "statefulsets", name: "db", verb: "patch"},
+	}
+}
+
+func TestBuildRules(t *testing.T) {
+	tests := []struct {
+		strategy string
+		expected []rbacv1.PolicyRule
+	}{
+		{
+			strategy: StrategyExact,
+			expected: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"list"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"a"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"b"}, Verbs: []string{"get", "update"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"s"}, Verbs: []string{"get"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, ResourceNames: []string{"web"}, Verbs: []string{"get"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}, ResourceNames: []string{"db"}, Verbs: []string{"patch"}},
+			},
+		},
+		{
+			strategy: StrategyResource,
+			expected: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "update"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "get"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}, Verbs: []string{"patch"}},
+			},
+		},
+		{
+			strategy: StrategyGroup,
+			expected: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps", "secrets"}, Verbs: []string{"get", "list", "update"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets"}, Verbs: []string{"create", "get", "patch"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			got := buildRules(grantsFixture(), tt.strategy)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected:\n%+v\ngot:\n%+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExactRulesShareNames(t *testing.T) {
+	grants := []grant{
+		{resource: "configmaps", name: "b", verb: "get"},
+		{resource: "configmaps", name: "a", verb: "get"},
+	}
+	expected := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"a", "b"}, Verbs: []string{"get"}},
+	}
+	if got := buildRules(grants, StrategyExact); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		maxRules    int
+		expected    string
+		explanation string
+	}{
+		{name: "default", expected: StrategyResource, explanation: explanations[StrategyResource]},
+		{name: "no limit", strategy: StrategyExact, expected: StrategyExact, explanation: explanations[StrategyExact]},
+		{name: "within the limit", strategy: StrategyExact, maxRules: 7, expected: StrategyExact, explanation: explanations[StrategyExact]},
+		{
+			name:        "falls back once",
+			strategy:    StrategyExact,
+			maxRules:    4,
+			expected:    StrategyResource,
+			explanation: "Fell back to the resource strategy: the exact strategy needs 7 rules, more than the maximum of 4. " + explanations[StrategyResource],
+		},
+		{
+			name:     "falls back twice",
+			strategy: StrategyExact,
+			maxRules: 2,
+			expected: StrategyGroup,
+			explanation: "Fell back to the group strategy: the exact strategy needs 7 rules, more than the maximum of 2, " +
+				"the resource strategy needs 4 rules, more than the maximum of 2. " + explanations[StrategyGroup],
+		},
+		{
+			name:        "still over the limit",
+			strategy:    StrategyGroup,
+			maxRules:    1,
+			expected:    StrategyGroup,
+			explanation: explanations[StrategyGroup] + " The role still needs 2 rules, more than the maximum of 1.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := minimize(grantsFixture(), tt.strategy, tt.maxRules)
+			if got.strategy != tt.expected {
+				t.Errorf("expected strategy %s, got %s", tt.expected, got.strategy)
+			}
+			if got.explanation != tt.explanation {
+				t.Errorf("expected explanation:\n%s\ngot:\n%s", tt.explanation, got.explanation)
+			}
+			if !reflect.DeepEqual(got.rules, buildRules(grantsFixture(), tt.expected)) {
+				t.Errorf("expected the rules of the %s strategy, got %+v", tt.expected, got.rules)
+			}
+		})
+	}
+}
+
+func TestGenerateAnnotatesRoles(t *testing.T) {
+	traced := []resources.Resource{
+		{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "a", Verbs: []string{"get"}},
+		{Version: "v1", Resource: "namespaces", Name: "demo", Verbs: []string{"get"}},
+	}
+
+	policy := Generate(traced, nil, Options{Name: "cdc", Strategy: StrategyExact})
+	for _, meta := range []metav1.ObjectMeta{policy.ClusterRole.ObjectMeta, policy.Roles[0].ObjectMeta} {
+		if meta.Annotations[AnnotationStrategy] != StrategyExact || meta.Annotations[AnnotationExplanation] != explanations[StrategyExact] {
+			t.Errorf("unexpected annotations %v", meta.Annotations)
+		}
+	}
+	if names := policy.Roles[0].Rules[0].ResourceNames; !reflect.DeepEqual(names, []string{"a"}) {
+		t.Errorf("expected the configmap to be granted by name, got %v", names)
+	}
+}
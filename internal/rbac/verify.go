@@ -0,0 +1,228 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	"github.com/krateoplatformops/chart-inspector/internal/tracer"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Grant is a set of rules granted in a namespace, as by a Role and a
+// RoleBinding, or on the whole cluster when Namespace is empty, as by a
+// ClusterRole and a ClusterRoleBinding.
+type Grant struct {
+	Namespace string              `json:"namespace,omitempty"`
+	Rules     []rbacv1.PolicyRule `json:"rules"`
+}
+
+// Verification is the outcome of a dry-run made under a set of grants.
+type Verification struct {
+	// Allowed is set when the dry-run completed without making any call the
+	// grants deny.
+	Allowed bool `json:"allowed"`
+	// Denied are the calls the grants deny, aggregated.
+	Denied []resources.Resource `json:"denied"`
+	// Error is why the dry-run failed, if it did. A denied call usually
+	// makes it fail, e.g. a forbidden lookup fails the template.
+	Error string `json:"error,omitempty"`
+	// Truncated is set when the tracer reached its limits.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Attributes are what the API server authorizes a resource request on.
+type Attributes struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Namespace   string
+	Name        string
+}
+
+// authorized returns attrs as the API server authorizes them: a Namespace is
+// authorized within itself.
+func authorized(attrs Attributes) Attributes {
+	if attrs.Group == "" && attrs.Resource == "namespaces" && attrs.Namespace == "" {
+		attrs.Namespace = attrs.Name
+	}
+	return attrs
+}
+
+// Allows tells whether grants allow a request with attrs, following the
+// rule matching of the Kubernetes RBAC authorizer.
+func Allows(grants []Grant, attrs Attributes) bool {
+	for _, g := range grants {
+		if g.Namespace != "" && g.Namespace != attrs.Namespace {
+			continue
+		}
+		for _, rule := range g.Rules {
+			if ruleAllows(rule, attrs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ruleAllows(rule rbacv1.PolicyRule, attrs Attributes) bool {
+	if !slices.Contains(rule.Verbs, attrs.Verb) && !slices.Contains(rule.Verbs, rbacv1.VerbAll) {
+		return false
+	}
+	if !slices.Contains(rule.APIGroups, attrs.Group) && !slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) {
+		return false
+	}
+
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	matches := slices.ContainsFunc(rule.Resources, func(el string) bool {
+		return el == rbacv1.ResourceAll || el == resource ||
+			(attrs.Subresource != "" && el == "*/"+attrs.Subresource)
+	})
+	if !matches {
+		return false
+	}
+
+	return len(rule.ResourceNames) == 0 || (attrs.Name != "" && slices.Contains(rule.ResourceNames, attrs.Name))
+}
+
+// Enforcer is an http.RoundTripper that answers the resource requests the
+// grants deny with a 403 Forbidden, as the API server would, and forwards
+// the others. Requests that are not resource requests, such as API
+// discovery, are always forwarded: every authenticated user may make them.
+type Enforcer struct {
+	http.RoundTripper
+	grants []Grant
+
+	mu     sync.Mutex
+	denied []resources.Resource
+}
+
+var _ http.RoundTripper = (*Enforcer)(nil)
+
+// NewEnforcer returns an Enforcer allowing the requests grants allow.
+func NewEnforcer(grants []Grant) *Enforcer {
+	return &Enforcer{grants: grants}
+}
+
+func (e *Enforcer) WithRoundTripper(rt http.RoundTripper) *Enforcer {
+	e.RoundTripper = rt
+	return e
+}
+
+// Denied returns the requests the enforcer denied, in the order they were
+// made.
+func (e *Enforcer) Denied() []resources.Resource {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.denied)
+}
+
+func (e *Enforcer) RoundTrip(req *http.Request) (*http.Response, error) {
+	info, ok := tracer.DefaultParser.Parse(req)
+	if !ok {
+		return e.RoundTripper.RoundTrip(req)
+	}
+
+	attrs := Attributes{
+		Verb:        info.Verb,
+		Group:       info.Group,
+		Resource:    info.Resource,
+		Subresource: info.Subresource,
+		Namespace:   info.Namespace,
+		Name:        info.Name,
+	}
+	if Allows(e.grants, authorized(attrs)) {
+		return e.RoundTripper.RoundTrip(req)
+	}
+
+	e.mu.Lock()
+	e.denied = append(e.denied, resources.Resource{
+		Group:       info.Group,
+		Version:     info.Version,
+		Resource:    info.Resource,
+		Subresource: info.Subresource,
+		Namespace:   info.Namespace,
+		Name:        info.Name,
+		Verbs:       []string{info.Verb},
+		Status:      http.StatusForbidden,
+	})
+	e.mu.Unlock()
+
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	return forbidden(req, attrs)
+}
+
+// forbidden builds the 403 Forbidden response the API server answers a
+// request denied by RBAC with.
+func forbidden(req *http.Request, attrs Attributes) (*http.Response, error) {
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	scope := "at the cluster scope"
+	if attrs.Namespace != "" {
+		scope = fmt.Sprintf("in the namespace %q", attrs.Namespace)
+	}
+	apiGroup := attrs.Group
+	if apiGroup == "" {
+		apiGroup = `""`
+	}
+
+	err := apierrors.NewForbidden(schema.GroupResource{Group: attrs.Group, Resource: attrs.Resource}, attrs.Name,
+		fmt.Errorf("the verified rules do not allow %s on resource %q in API group %s %s", attrs.Verb, resource, apiGroup, scope))
+	status := err.ErrStatus
+	status.Kind, status.APIVersion = "Status", "v1"
+
+	body, merr := json.Marshal(status)
+	if merr != nil {
+		return nil, merr
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusForbidden, http.StatusText(http.StatusForbidden)),
+		StatusCode:    http.StatusForbidden,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Verify builds the verification of a dry-run made through e, that failed
+// with installErr when not nil.
+func (e *Enforcer) Verify(installErr error) Verification {
+	res := Verification{
+		Denied: tracer.Aggregate(e.Denied()),
+	}
+	res.Allowed = len(res.Denied) == 0 && installErr == nil
+	if installErr != nil {
+		res.Error = installErr.Error()
+	}
+	return res
+}
+
+// ParseRoleRef splits a "namespace/name" reference to a Role.
+func ParseRoleRef(ref string) (namespace, name string, ok bool) {
+	namespace, name, ok = strings.Cut(ref, "/")
+	return namespace, name, ok && namespace != "" && name != ""
+}
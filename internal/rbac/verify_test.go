@@ -0,0 +1,135 @@
+package rbac
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAllows(t *testing.T) {
+	grants := []Grant{
+		{Namespace: "demo", Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}},
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"creds"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"*/scale"}, Verbs: []string{"*"}},
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get"}},
+		}},
+		{Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get"}},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		attrs    Attributes
+		expected bool
+	}{
+		{name: "namespaced get", attrs: Attributes{Verb: "get", Resource: "configmaps", Namespace: "demo", Name: "a"}, expected: true},
+		{name: "other namespace", attrs: Attributes{Verb: "get", Resource: "configmaps", Namespace: "other", Name: "a"}},
+		{name: "cluster-wide list", attrs: Attributes{Verb: "list", Resource: "configmaps"}},
+		{name: "other verb", attrs: Attributes{Verb: "delete", Resource: "configmaps", Namespace: "demo", Name: "a"}},
+		{name: "named object", attrs: Attributes{Verb: "get", Resource: "secrets", Namespace: "demo", Name: "creds"}, expected: true},
+		{name: "other object", attrs: Attributes{Verb: "get", Resource: "secrets", Namespace: "demo", Name: "token"}},
+		{name: "collection of named objects", attrs: Attributes{Verb: "get", Resource: "secrets", Namespace: "demo"}},
+		{name: "any subresource", attrs: Attributes{Verb: "update", Group: "apps", Resource: "deployments", Subresource: "scale", Namespace: "demo", Name: "web"}, expected: true},
+		{name: "parent of subresource", attrs: Attributes{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "demo", Name: "web"}},
+		{name: "cluster scope", attrs: Attributes{Verb: "get", Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Name: "widgets.example.io"}, expected: true},
+		{name: "cluster rule in namespace", attrs: Attributes{Verb: "get", Group: "example.io", Resource: "customresourcedefinitions", Namespace: "demo"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allows(grants, tt.attrs); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+type okRoundTripper struct {
+	calls int
+}
+
+func (rt *okRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestEnforcer(t *testing.T) {
+	next := &okRoundTripper{}
+	e := NewEnforcer([]Grant{
+		{Namespace: "demo", Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"configmaps", "namespaces"}, Verbs: []string{"get"}},
+		}},
+	}).WithRoundTripper(next)
+
+	for _, path := range []string{"/api/v1/namespaces/demo/configmaps/a", "/api/v1/namespaces/demo", "/apis"} {
+		resp, err := e.RoundTrip(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected the call to be forwarded, got %v, %v", path, resp, err)
+		}
+	}
+	if next.calls != 3 {
+		t.Errorf("expected 3 forwarded calls, got %d", next.calls)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/demo/secrets", strings.NewReader(`{"kind":"Secret"}`))
+	resp, err := e.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden || next.calls != 3 {
+		t.Fatalf("expected a 403 without forwarding, got %d after %d calls", resp.StatusCode, next.calls)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	status := metav1.Status{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Kind != "Status" || status.Reason != metav1.StatusReasonForbidden || status.Code != http.StatusForbidden ||
+		!strings.Contains(status.Message, `do not allow create on resource "secrets"`) {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	expected := []resources.Resource{
+		{Version: "v1", Resource: "secrets", Namespace: "demo", Verbs: []string{"create"}, Status: http.StatusForbidden},
+	}
+	if got := e.Denied(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected denied %+v, got %+v", expected, got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	e := NewEnforcer(nil).WithRoundTripper(&okRoundTripper{})
+	if got := e.Verify(nil); !got.Allowed || len(got.Denied) != 0 {
+		t.Errorf("expected an allowed verification, got %+v", got)
+	}
+
+	for range 2 {
+		e.RoundTrip(httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/demo/configmaps/a", nil))
+	}
+	got := e.Verify(errors.New("configmaps \"a\" is forbidden"))
+	if got.Allowed || len(got.Denied) != 1 || got.Denied[0].Count != 2 || got.Error == "" {
+		t.Errorf("expected one denied call made twice and the error, got %+v", got)
+	}
+}
+
+func TestParseRoleRef(t *testing.T) {
+	if ns, name, ok := ParseRoleRef("demo/cdc"); !ok || ns != "demo" || name != "cdc" {
+		t.Errorf("unexpected %q %q %v", ns, name, ok)
+	}
+	for _, ref := range []string{"cdc", "/cdc", "demo/"} {
+		if _, _, ok := ParseRoleRef(ref); ok {
+			t.Errorf("expected %q to be rejected", ref)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package replaytest serves the demo composition of testdata to the tests of
+// the handlers: its objects and its dry-run are replayed from the checked-in
+// cassette, and its chart from a chart cache, so nothing listens on the API
+// server.
+package replaytest
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/flect"
+	"github.com/krateoplatformops/chart-inspector/internal/handlers"
+	"github.com/krateoplatformops/chart-inspector/internal/tracer"
+	"github.com/krateoplatformops/plumbing/helm/getter/cache"
+	helmv3 "github.com/krateoplatformops/plumbing/helm/v3"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// Host is the API server of the tests. Nothing listens on it.
+	Host = "https://127.0.0.1:1"
+	// Query selects the demo composition and its definition.
+	Query = "compositionName=demo&compositionNamespace=demo-system" +
+		"&compositionDefinitionName=demo&compositionDefinitionNamespace=demo-system" +
+		"&compositionVersion=v0-1-0&compositionResource=demos"
+	// Cassette is the file name of the cassette of the demo composition.
+	Cassette = "demo-system_demo.har"
+
+	// chartURL is the chart of the demo CompositionDefinition, served from
+	// the chart cache.
+	chartURL     = "https://charts.krateo.io/demo-0.1.0.tgz"
+	chartVersion = "0.1.0"
+)
+
+// testdata returns the path of elem in the testdata directory of the module.
+func testdata(elem ...string) string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(append([]string{filepath.Dir(file), "..", "..", "testdata"}, elem...)...)
+}
+
+// CassetteDir returns the directory of the checked-in cassettes.
+func CassetteDir() string {
+	return testdata("cassettes")
+}
+
+// Pluralizer pluralizes the kinds of the demo objects.
+type Pluralizer struct{}
+
+func (Pluralizer) GVKtoGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	return schema.GroupVersionResource{
+		Group:    gvk.Group,
+		Version:  gvk.Version,
+		Resource: flect.Pluralize(strings.ToLower(gvk.Kind)),
+	}, nil
+}
+
+// Options returns the options of a service talking to the API server of cfg
+// and keeping its cassettes in cassetteDir, whose Helm client and chart cache
+// find the demo chart.
+func Options(t testing.TB, cfg *rest.Config, cassetteDir string) handlers.HandlerOptions {
+	t.Helper()
+
+	chart, err := loader.LoadDir(testdata("charts", "demo"))
+	if err != nil {
+		t.Fatalf("unable to load the demo chart: %v", err)
+	}
+	archive, err := chartutil.Save(chart, t.TempDir())
+	if err != nil {
+		t.Fatalf("unable to package the demo chart: %v", err)
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("unable to open the demo chart: %v", err)
+	}
+	defer f.Close()
+
+	cacheDir := t.TempDir()
+	chartCache, err := cache.NewDiskCache(cache.WithDir(cacheDir))
+	if err != nil {
+		t.Fatalf("unable to create the chart cache: %v", err)
+	}
+	t.Cleanup(chartCache.Stop)
+	if err := chartCache.Set(chartURL, chartVersion, f); err != nil {
+		t.Fatalf("unable to cache the demo chart: %v", err)
+	}
+
+	helmClient, err := helmv3.NewClient(cfg, helmv3.WithCache(cache.WithDir(cacheDir)))
+	if err != nil {
+		t.Fatalf("unable to create the helm client: %v", err)
+	}
+	t.Cleanup(func() { helmClient.Close() })
+
+	return handlers.HandlerOptions{
+		Log:             slog.New(slog.DiscardHandler),
+		KrateoNamespace: "krateo-system",
+		Plurarizer:      Pluralizer{},
+		RestConfig:      cfg,
+		HelmClient:      helmClient,
+		ChartCache:      chartCache,
+		CassetteDir:     cassetteDir,
+	}
+}
+
+// Cluster answers the reads of the demo objects and the calls of their
+// dry-run out of the checked-in cassette, as the API server would.
+func Cluster(t testing.TB) http.RoundTripper {
+	t.Helper()
+
+	calls, err := tracer.LoadCassette(filepath.Join(CassetteDir(), Cassette))
+	if err != nil {
+		t.Fatalf("unable to load the cassette: %v", err)
+	}
+	return cluster{fixtures: calls.Fixtures(), calls: calls}
+}
+
+type cluster struct {
+	fixtures *tracer.Cassette
+	calls    *tracer.Cassette
+}
+
+func (c cluster) RoundTrip(req *http.Request) (*http.Response, error) {
+	if resp, err := c.fixtures.RoundTrip(req); err == nil {
+		return resp, nil
+	}
+	return c.calls.RoundTrip(req)
+}
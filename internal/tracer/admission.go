@@ -0,0 +1,77 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// maxStatusBytes bounds how much of an error response is read to find the
+// admission rejection message.
+const maxStatusBytes = 1 << 20
+
+// responseWarnings returns the texts of the Warning headers of resp, such as
+// API deprecation notices or warnings issued by admission webhooks and
+// ValidatingAdmissionPolicies.
+func responseWarnings(resp *http.Response) []string {
+	warnings, _ := utilnet.ParseWarningHeaders(resp.Header.Values("Warning"))
+
+	var res []string
+	for _, w := range warnings {
+		res = append(res, w.Text)
+	}
+	return res
+}
+
+// admissionRejection returns the message of the Status the API server answered
+// a rejected write with, when the rejection comes from admission (webhooks,
+// ValidatingAdmissionPolicies, built-in admission plugins) rather than from
+// authorization or from the state of the object. The response body is read
+// and replaced so that the caller still receives it.
+func admissionRejection(resp *http.Response, verb string) string {
+	switch verb {
+	case "get", "list", "watch":
+		return ""
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity, http.StatusInternalServerError:
+	default:
+		return ""
+	}
+
+	if resp.Body == nil {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxStatusBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	if err != nil {
+		return ""
+	}
+
+	status := metav1.Status{}
+	if err := json.Unmarshal(data, &status); err != nil || status.Kind != "Status" {
+		return ""
+	}
+
+	if isAuthorizationDenial(status.Message) {
+		return ""
+	}
+
+	return status.Message
+}
+
+// isAuthorizationDenial reports whether msg is the message of an RBAC denial,
+// e.g. `deployments.apps "web" is forbidden: User "x" cannot create resource
+// "deployments" in API group "apps" in the namespace "demo"`.
+func isAuthorizationDenial(msg string) bool {
+	return strings.Contains(msg, " cannot ") && strings.Contains(msg, ` resource "`)
+}
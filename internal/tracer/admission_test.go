@@ -0,0 +1,138 @@
+package tracer
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixedRoundTripper is a mock RoundTripper answering every call with the same
+// status, headers and body.
+type fixedRoundTripper struct {
+	code   int
+	header http.Header
+	body   string
+}
+
+func (rt *fixedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := rt.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: rt.code,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(rt.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestTracerCollectsWarnings(t *testing.T) {
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(&fixedRoundTripper{
+		code: http.StatusCreated,
+		header: http.Header{"Warning": []string{
+			`299 - "policy/v1beta1 PodDisruptionBudget is deprecated in v1.21+, unavailable in v1.25+; use policy/v1 PodDisruptionBudget"`,
+			`299 - "missing label: team", 299 - "missing label: cost-center"`,
+		}},
+		body: "{}",
+	})
+
+	u, _ := url.Parse("/apis/policy/v1beta1/namespaces/demo/poddisruptionbudgets?dryRun=All")
+	tracer.RoundTrip(&http.Request{Method: http.MethodPost, URL: u, Header: http.Header{}})
+
+	got := tracer.GetResources()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(got))
+	}
+
+	expected := []string{
+		"policy/v1beta1 PodDisruptionBudget is deprecated in v1.21+, unavailable in v1.25+; use policy/v1 PodDisruptionBudget",
+		"missing label: team",
+		"missing label: cost-center",
+	}
+	if !reflect.DeepEqual(got[0].Warnings, expected) {
+		t.Errorf("expected warnings %q, got %q", expected, got[0].Warnings)
+	}
+}
+
+func TestTracerCollectsAdmissionRejections(t *testing.T) {
+	webhookDenial := `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"admission webhook \"validate.kyverno.svc\" denied the request: label team is required","reason":"BadRequest","code":400}`
+	policyDenial := `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"deployments.apps \"web\" is forbidden: ValidatingAdmissionPolicy 'replicas' with binding 'replicas' denied request: replicas must be at most 5","reason":"Invalid","code":422}`
+	rbacDenial := `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"deployments.apps is forbidden: User \"system:serviceaccount:demo:cdc\" cannot create resource \"deployments\" in API group \"apps\" in the namespace \"demo\"","reason":"Forbidden","code":403}`
+	conflict := `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"deployments.apps \"web\" already exists","reason":"AlreadyExists","code":409}`
+
+	tests := []struct {
+		name     string
+		method   string
+		code     int
+		body     string
+		expected string
+	}{
+		{
+			name:     "webhook",
+			method:   http.MethodPost,
+			code:     http.StatusBadRequest,
+			body:     webhookDenial,
+			expected: `admission webhook "validate.kyverno.svc" denied the request: label team is required`,
+		},
+		{
+			name:     "validating admission policy",
+			method:   http.MethodPost,
+			code:     http.StatusUnprocessableEntity,
+			body:     policyDenial,
+			expected: `deployments.apps "web" is forbidden: ValidatingAdmissionPolicy 'replicas' with binding 'replicas' denied request: replicas must be at most 5`,
+		},
+		{
+			name:   "rbac",
+			method: http.MethodPost,
+			code:   http.StatusForbidden,
+			body:   rbacDenial,
+		},
+		{
+			name:   "conflict",
+			method: http.MethodPost,
+			code:   http.StatusConflict,
+			body:   conflict,
+		},
+		{
+			name:   "read",
+			method: http.MethodGet,
+			code:   http.StatusForbidden,
+			body:   webhookDenial,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := &Tracer{}
+			tracer.WithRoundTripper(&fixedRoundTripper{code: tt.code, body: tt.body})
+
+			u, _ := url.Parse("/apis/apps/v1/namespaces/demo/deployments?dryRun=All")
+			resp, err := tracer.RoundTrip(&http.Request{Method: tt.method, URL: u, Header: http.Header{}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The caller must still receive the whole response body.
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected error reading body: %v", err)
+			}
+			if string(b) != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, b)
+			}
+
+			got := tracer.GetResources()
+			if len(got) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(got))
+			}
+			if got[0].Admission != tt.expected {
+				t.Errorf("expected admission %q, got %q", tt.expected, got[0].Admission)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// object holds the identity fields of a Kubernetes object sent in a request
+// body.
+type object struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+// carriesObject reports whether req sends a whole object in its body, i.e. it
+// is a create or an update of the resource itself (not of a subresource such
+// as pods/exec or deployments/scale, whose bodies are different types).
+func carriesObject(req *http.Request, info *RequestInfo) bool {
+	if info.Subresource != "" || req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		return false
+	}
+
+	// Protobuf bodies cannot be decoded without the type scheme.
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// teeRequestBody reads the body of req and decodes the object it carries. It
+// returns a request whose body can still be sent to the API server, see
+// bufferRequestBody.
+func teeRequestBody(req *http.Request) (*http.Request, *object, error) {
+	req, data, err := bufferRequestBody(req)
+	if err != nil {
+		return req, nil, err
+	}
+
+	obj := &object{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		// Not an object we understand: the request is still forwarded as is.
+		return req, nil, nil
+	}
+
+	return req, obj, nil
+}
+
+// bufferRequestBody reads the body of req. It returns a request whose body can
+// still be sent to the API server: either req itself, when the body could be
+// obtained through req.GetBody, or a shallow clone of req with the buffered
+// body.
+func bufferRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return req, nil, err
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return req, nil, err
+		}
+		return req, data, nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return req, nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return req, data, nil
+}
+
+// applyObject fills in the fields of res that the request path could not
+// provide with the identity of the object sent in the request body. For a
+// create the path has no object name, and the namespace may be set only in
+// the manifest.
+func applyObject(res *resources.Resource, obj *object) {
+	res.Kind = obj.Kind
+	if res.Name == "" {
+		res.Name = obj.Metadata.Name
+	}
+	if res.Namespace == "" {
+		res.Namespace = obj.Metadata.Namespace
+	}
+}
@@ -0,0 +1,171 @@
+package tracer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// bodyRecorder is a mock RoundTripper that keeps the body it receives.
+type bodyRecorder struct {
+	body []byte
+}
+
+func (rt *bodyRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		rt.body = b
+	}
+	return (&NoOpRoundTripper{}).RoundTrip(req)
+}
+
+const deploymentManifest = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"demo"},"spec":{}}`
+
+func TestTracerDecodesDryRunCreate(t *testing.T) {
+	rec := &bodyRecorder{}
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(rec)
+
+	u, _ := url.Parse("/apis/apps/v1/namespaces/demo/deployments?dryRun=All")
+	req := &http.Request{
+		Method: http.MethodPost,
+		URL:    u,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   io.NopCloser(strings.NewReader(deploymentManifest)),
+	}
+	if _, err := tracer.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(rec.body) != deploymentManifest {
+		t.Errorf("expected the nested RoundTripper to receive the full body, got %q", rec.body)
+	}
+
+	expected := []resources.Resource{{
+		Group:     "apps",
+		Version:   "v1",
+		Resource:  "deployments",
+		Namespace: "demo",
+		Name:      "web",
+		Kind:      "Deployment",
+		Verbs:     []string{"create"},
+		Origin:    resources.OriginRendered,
+		Status:    http.StatusOK,
+	}}
+	if got := tracer.GetResources(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestTracerDecodesBodyThroughGetBody(t *testing.T) {
+	rec := &bodyRecorder{}
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(rec)
+
+	manifest := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cfg","namespace":"demo"}}`
+	u, _ := url.Parse("/api/v1/configmaps")
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewBufferString(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracer.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(rec.body) != manifest {
+		t.Errorf("expected the nested RoundTripper to receive the full body, got %q", rec.body)
+	}
+
+	got := tracer.GetResources()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(got))
+	}
+	if got[0].Kind != "ConfigMap" || got[0].Name != "cfg" || got[0].Namespace != "demo" {
+		t.Errorf("unexpected resource: %+v", got[0])
+	}
+}
+
+func TestTracerSkipsUndecodableBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "protobuf",
+			path:        "/apis/apps/v1/namespaces/demo/deployments",
+			contentType: "application/vnd.kubernetes.protobuf",
+			body:        "k8s\x00\n",
+		},
+		{
+			name: "invalid json",
+			path: "/apis/apps/v1/namespaces/demo/deployments",
+			body: "{not json",
+		},
+		{
+			name:        "subresource",
+			path:        "/api/v1/namespaces/demo/pods/web-0/eviction",
+			contentType: "application/json",
+			body:        `{"apiVersion":"policy/v1","kind":"Eviction","metadata":{"name":"web-0"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &bodyRecorder{}
+			tracer := &Tracer{}
+			tracer.WithRoundTripper(rec)
+
+			u, _ := url.Parse(tt.path)
+			req := &http.Request{
+				Method: http.MethodPost,
+				URL:    u,
+				Header: http.Header{},
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if _, err := tracer.RoundTrip(req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(rec.body) != tt.body {
+				t.Errorf("expected the body to be forwarded untouched, got %q", rec.body)
+			}
+
+			got := tracer.GetResources()
+			if len(got) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(got))
+			}
+			if got[0].Kind != "" {
+				t.Errorf("expected no kind to be decoded, got %q", got[0].Kind)
+			}
+		})
+	}
+}
+
+func TestAggregateKeepsDecodedKind(t *testing.T) {
+	li := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Verbs: []string{"get"}},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Name: "web", Kind: "Deployment", Verbs: []string{"create"}},
+	}
+
+	got := Aggregate(li)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(got))
+	}
+	if got[0].Kind != "Deployment" {
+		t.Errorf("expected kind Deployment, got %q", got[0].Kind)
+	}
+}
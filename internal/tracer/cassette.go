@@ -0,0 +1,177 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrTruncated is returned by SaveCassette when a tracer reached its limits:
+// a replay of its exchanges would fail or differ from the recorded dry-run.
+var ErrTruncated = errors.New("the tracer reached its limits, some exchanges or bodies are missing")
+
+// SaveCassette writes the exchanges captured by the tracer to path, so that
+// they can be replayed later with LoadCassette. The cassette is a HAR archive
+// whose credential headers are redacted but whose bodies are kept as they
+// are, Secrets included, since a replay needs them: the file is created
+// readable by its owner only. The exchanges captured by fixtures, when not
+// nil, are saved as the fixtures of the cassette. Both tracers must be built
+// WithCapture, and nothing is written when one of them is Truncated.
+func (t *Tracer) SaveCassette(path string, fixtures *Tracer) error {
+	if !t.capture || (fixtures != nil && !fixtures.capture) {
+		return fmt.Errorf("tracer does not capture exchanges")
+	}
+	if t.Truncated() || (fixtures != nil && fixtures.Truncated()) {
+		return ErrTruncated
+	}
+
+	har := t.HAR(false)
+	if fixtures != nil {
+		har.Log.Fixtures = fixtures.HAR(false).Log.Entries
+	}
+	data, err := json.Marshal(har)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Cassette is an http.RoundTripper that answers requests from a recording
+// instead of sending them to the API server. A request is answered with the
+// first recorded exchange with the same method, path and query parameters
+// that has not been replayed yet; once all of them have been replayed, the
+// last one is replayed again. A request that was never recorded fails.
+type Cassette struct {
+	mu       sync.Mutex
+	entries  []HAREntry
+	replayed []bool
+	fixtures []HAREntry
+}
+
+var _ http.RoundTripper = (*Cassette)(nil)
+
+// NewCassette returns a Cassette replaying the entries of har.
+func NewCassette(har HAR) *Cassette {
+	return &Cassette{
+		entries:  har.Log.Entries,
+		replayed: make([]bool, len(har.Log.Entries)),
+		fixtures: har.Log.Fixtures,
+	}
+}
+
+// Fixtures returns a Cassette replaying the fixtures of c.
+func (c *Cassette) Fixtures() *Cassette {
+	return NewCassette(HAR{Log: HARLog{Entries: c.fixtures}})
+}
+
+// LoadCassette reads a cassette written by Tracer.SaveCassette.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	har := HAR{}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("unable to decode cassette %s: %w", path, err)
+	}
+	return NewCassette(har), nil
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	key := replayKey(req.URL)
+
+	c.mu.Lock()
+	match := -1
+	for i, el := range c.entries {
+		if el.Request.Method != method {
+			continue
+		}
+		u, err := url.Parse(el.Request.URL)
+		if err != nil || replayKey(u) != key {
+			continue
+		}
+		match = i
+		if !c.replayed[i] {
+			break
+		}
+	}
+	if match >= 0 {
+		c.replayed[match] = true
+	}
+	c.mu.Unlock()
+
+	if match < 0 {
+		return nil, fmt.Errorf("cassette has no recorded response for %s %s", method, req.URL)
+	}
+
+	entry := c.entries[match]
+	if entry.Error != "" && entry.Response.Status == 0 {
+		return nil, errors.New(entry.Error)
+	}
+
+	return replayResponse(req, entry.Response)
+}
+
+// replayKey identifies a request by its path and its query parameters,
+// regardless of their order and of the host the recording was made against.
+func replayKey(u *url.URL) string {
+	return strings.TrimSuffix(u.Path, "/") + "?" + u.Query().Encode()
+}
+
+// replayResponse builds the response to req out of a recorded one.
+func replayResponse(req *http.Request, rec HARResponse) (*http.Response, error) {
+	body := []byte(rec.Content.Text)
+	if rec.Content.Encoding == "base64" {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(rec.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode recorded response body for %s %s: %w", req.Method, req.URL, err)
+		}
+	}
+
+	header := http.Header{}
+	for _, el := range rec.Headers {
+		header.Add(el.Name, el.Value)
+	}
+
+	proto := httpVersion(rec.HTTPVersion)
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,214 @@
+package tracer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sequenceRoundTripper is a mock RoundTripper answering the n-th call with the
+// n-th body.
+type sequenceRoundTripper struct {
+	bodies []string
+	calls  int
+}
+
+func (rt *sequenceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := rt.bodies[rt.calls%len(rt.bodies)]
+	rt.calls++
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+			"Warning":      []string{`299 - "deprecated"`},
+		},
+		Body:    io.NopCloser(strings.NewReader(body)),
+		Request: req,
+	}, nil
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	recorder := (&Tracer{}).WithCapture()
+	recorder.WithRoundTripper(&sequenceRoundTripper{bodies: []string{
+		`{"kind":"SecretList","items":[]}`,
+		secretManifest,
+		`{"kind":"Secret","metadata":{"name":"creds"},"data":{"password":"bmV3"}}`,
+	}})
+
+	calls := []string{
+		"https://10.96.0.1/api/v1/namespaces/demo/secrets?labelSelector=owner%3Dhelm&limit=500",
+		"https://10.96.0.1/api/v1/namespaces/demo/secrets/creds",
+		"https://10.96.0.1/api/v1/namespaces/demo/secrets/creds",
+	}
+	for _, call := range calls {
+		u, _ := url.Parse(call)
+		if _, err := recorder.RoundTrip(&http.Request{
+			Method: http.MethodGet,
+			URL:    u,
+			Header: http.Header{"Authorization": []string{"Bearer s3cr3t"}},
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "cassettes", "demo_focus.har")
+	if err := recorder.SaveCassette(path, nil); err != nil {
+		t.Fatalf("unexpected error saving the cassette: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading the cassette: %v", err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("expected the cassette not to contain the bearer token")
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading the cassette: %v", err)
+	}
+
+	replayer := &Tracer{}
+	replayer.WithRoundTripper(cassette)
+
+	// The query parameters may come in any order and the host may differ.
+	replays := []struct {
+		url  string
+		body string
+	}{
+		{url: "https://127.0.0.1:6443/api/v1/namespaces/demo/secrets?limit=500&labelSelector=owner%3Dhelm", body: `{"kind":"SecretList","items":[]}`},
+		{url: "https://127.0.0.1:6443/api/v1/namespaces/demo/secrets/creds", body: secretManifest},
+		{url: "https://127.0.0.1:6443/api/v1/namespaces/demo/secrets/creds", body: `{"kind":"Secret","metadata":{"name":"creds"},"data":{"password":"bmV3"}}`},
+		{url: "https://127.0.0.1:6443/api/v1/namespaces/demo/secrets/creds", body: `{"kind":"Secret","metadata":{"name":"creds"},"data":{"password":"bmV3"}}`},
+	}
+	for _, replay := range replays {
+		u, _ := url.Parse(replay.url)
+		resp, err := replayer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}})
+		if err != nil {
+			t.Fatalf("unexpected error replaying %s: %v", replay.url, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != replay.body {
+			t.Errorf("expected %s to be replayed with %s, got %s", replay.url, replay.body, b)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected replayed response %d %v", resp.StatusCode, resp.Header)
+		}
+	}
+
+	got := replayer.GetResources()
+	if len(got) != 4 {
+		t.Fatalf("expected 4 traced resources, got %d", len(got))
+	}
+	if !reflect.DeepEqual(got[0].Warnings, []string{"deprecated"}) {
+		t.Errorf("expected the recorded warnings to be replayed, got %q", got[0].Warnings)
+	}
+}
+
+func TestCassetteFixtures(t *testing.T) {
+	recorder := (&Tracer{}).WithCapture()
+	recorder.WithRoundTripper(&sequenceRoundTripper{bodies: []string{`{"kind":"SecretList","items":[]}`}})
+	fixtures := (&Tracer{}).WithCapture()
+	fixtures.WithRoundTripper(&sequenceRoundTripper{bodies: []string{secretManifest}})
+
+	for _, call := range []struct {
+		tracer *Tracer
+		url    string
+	}{
+		{tracer: fixtures, url: "https://10.96.0.1/api/v1/namespaces/demo/secrets/creds"},
+		{tracer: recorder, url: "https://10.96.0.1/api/v1/namespaces/demo/secrets"},
+	} {
+		u, _ := url.Parse(call.url)
+		if _, err := call.tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "demo_focus.har")
+	if err := recorder.SaveCassette(path, fixtures); err != nil {
+		t.Fatalf("unexpected error saving the cassette: %v", err)
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading the cassette: %v", err)
+	}
+
+	u, _ := url.Parse("https://127.0.0.1:6443/api/v1/namespaces/demo/secrets/creds")
+	if _, err := cassette.RoundTrip(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+		t.Errorf("expected a fixture not to be replayed as a traced call")
+	}
+	resp, err := cassette.Fixtures().RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("unexpected error replaying the fixture: %v", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != secretManifest {
+		t.Errorf("expected the fixture to be replayed with %s, got %s", secretManifest, b)
+	}
+}
+
+func TestCassetteUnrecordedRequest(t *testing.T) {
+	cassette := NewCassette(HAR{Log: HARLog{Entries: []HAREntry{{
+		Request:  HARRequest{Method: http.MethodGet, URL: "https://10.96.0.1/api/v1/namespaces/demo/configmaps/settings"},
+		Response: HARResponse{Status: http.StatusOK},
+	}}}})
+
+	tests := []struct {
+		method string
+		url    string
+	}{
+		{method: http.MethodGet, url: "https://10.96.0.1/api/v1/namespaces/demo/configmaps/other"},
+		{method: http.MethodDelete, url: "https://10.96.0.1/api/v1/namespaces/demo/configmaps/settings"},
+		{method: http.MethodGet, url: "https://10.96.0.1/api/v1/namespaces/demo/configmaps/settings?resourceVersion=0"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if _, err := cassette.RoundTrip(&http.Request{Method: tt.method, URL: u}); err == nil {
+			t.Errorf("expected %s %s to fail", tt.method, tt.url)
+		}
+	}
+}
+
+func TestSaveCassetteTruncated(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+	}{
+		{name: "max calls", limits: Limits{MaxCalls: 1}},
+		{name: "max body bytes", limits: Limits{MaxBodyBytes: 16}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := (&Tracer{}).WithCapture().WithLimits(tt.limits)
+			recorder.WithRoundTripper(&sequenceRoundTripper{bodies: []string{secretManifest}})
+			for range 2 {
+				u, _ := url.Parse("https://10.96.0.1/api/v1/namespaces/demo/secrets/creds")
+				if _, err := recorder.RoundTrip(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			path := filepath.Join(t.TempDir(), "demo_focus.har")
+			if err := recorder.SaveCassette(path, nil); !errors.Is(err, ErrTruncated) {
+				t.Errorf("expected error %v, got %v", ErrTruncated, err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("expected no cassette to be written, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSaveCassetteWithoutCapture(t *testing.T) {
+	tracer := &Tracer{}
+	if err := tracer.SaveCassette(filepath.Join(t.TempDir(), "demo_focus.har"), nil); err == nil {
+		t.Errorf("expected an error saving a cassette without capture")
+	}
+}
@@ -0,0 +1,425 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// redacted replaces the sensitive values of an exported exchange.
+const redacted = "REDACTED"
+
+// sensitiveHeaders are the headers whose values are always redacted from an
+// exported exchange. Authorization carries the bearer token of the service
+// account running the dry-run, the Impersonate-* headers the identity it acts
+// as.
+var sensitiveHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+	"Impersonate-User", "Impersonate-Group", "Impersonate-Uid",
+}
+
+// sensitiveHeaderPrefix is the prefix of the Impersonate-Extra-<key> headers,
+// which are redacted as well.
+const sensitiveHeaderPrefix = "Impersonate-Extra-"
+
+// exchange is a request and its response as seen by the tracer, kept when the
+// tracer captures exchanges.
+type exchange struct {
+	started        time.Time
+	wait           time.Duration
+	receive        time.Duration
+	method         string
+	url            string
+	proto          string
+	requestHeader  http.Header
+	requestBody    []byte
+	status         int
+	responseProto  string
+	responseHeader http.Header
+	responseBody   []byte
+	err            string
+	// truncated is set when the bodies were dropped because of the
+	// MaxBodyBytes limit, the sizes are still those of the original bodies.
+	truncated    bool
+	requestSize  int
+	responseSize int
+}
+
+// HAR is an HTTP Archive, as specified by
+// http://www.softwareishard.com/blog/har-12-spec/.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+	// Fixtures are the exchanges a cassette replays outside of the traced
+	// calls, e.g. the reads of the objects the dry-run is made for.
+	Fixtures []HAREntry `json:"_fixtures,omitempty"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	// Error is the error the call failed with, when the API server could not
+	// be reached. HAR allows custom fields prefixed with an underscore.
+	Error string `json:"_error,omitempty"`
+	// Truncated is set when the bodies of the exchange were not kept because
+	// the tracer reached its MaxBodyBytes limit.
+	Truncated bool `json:"_truncated,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is "base64" when Text holds a binary body, as for HARContent.
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// WithCapture makes the tracer keep the full exchange of every call,
+// including discovery calls and the request and response bodies, so that it
+// can be exported with HAR. Watch responses are streams: only their headers
+// are kept.
+func (t *Tracer) WithCapture() *Tracer {
+	t.capture = true
+	return t
+}
+
+// HAR returns the exchanges captured by the tracer as an HTTP Archive, in the
+// order they were made. The values of the credential headers (Authorization,
+// Cookie, Impersonate-* and the like) are always replaced with "REDACTED";
+// when redactSecrets is set the data of Secrets is replaced as well.
+// The archive is empty unless the tracer was built WithCapture.
+func (t *Tracer) HAR(redactSecrets bool) HAR {
+	t.mu.Lock()
+	exchanges := slices.Clone(t.exchanges)
+	truncated := t.truncated
+	t.mu.Unlock()
+
+	entries := []HAREntry{}
+	for _, ex := range exchanges {
+		entries = append(entries, ex.entry(redactSecrets))
+	}
+
+	res := HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "chart-inspector", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+	if truncated {
+		res.Log.Comment = "truncated: the tracer reached its limits, some exchanges or bodies are missing"
+	}
+	return res
+}
+
+// roundTrip calls the nested RoundTripper and, when the tracer captures
+// exchanges, records the exchange. The response body is then read in full and
+// replaced, unless stream is set.
+func (t *Tracer) roundTrip(req *http.Request, stream bool) (*http.Response, error) {
+	if !t.capture {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	ex := exchange{
+		started:       time.Now(),
+		method:        req.Method,
+		url:           req.URL.String(),
+		proto:         req.Proto,
+		requestHeader: req.Header.Clone(),
+	}
+	if ex.method == "" {
+		ex.method = http.MethodGet
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		req, ex.requestBody, err = bufferRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	ex.wait = time.Since(ex.started)
+	if err != nil {
+		ex.err = err.Error()
+	}
+
+	if resp != nil {
+		ex.status = resp.StatusCode
+		ex.responseProto = resp.Proto
+		ex.responseHeader = resp.Header.Clone()
+
+		if !stream && resp.Body != nil {
+			data, rerr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+			ex.responseBody = data
+			ex.receive = time.Since(ex.started) - ex.wait
+			if rerr != nil && err == nil {
+				ex.err = rerr.Error()
+				err = rerr
+			}
+		}
+	}
+
+	t.mu.Lock()
+	if !t.callsFull(len(t.exchanges)) {
+		ex.requestSize, ex.responseSize = len(ex.requestBody), len(ex.responseBody)
+		if !t.reserveBodyBytes(ex.requestSize + ex.responseSize) {
+			ex.requestBody, ex.responseBody = nil, nil
+			ex.truncated = true
+		}
+		t.exchanges = append(t.exchanges, ex)
+	}
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+// entry converts ex to a HAR entry, redacting the credential headers.
+func (ex exchange) entry(redactSecrets bool) HAREntry {
+	requestBody, responseBody := ex.requestBody, ex.responseBody
+	if redactSecrets {
+		requestBody = redactSecretData(ex.url, requestBody)
+		responseBody = redactSecretData(ex.url, responseBody)
+	}
+
+	req := HARRequest{
+		Method:      ex.method,
+		URL:         ex.url,
+		HTTPVersion: httpVersion(ex.proto),
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(ex.requestHeader),
+		QueryString: harQueryString(ex.url),
+		HeadersSize: -1,
+		BodySize:    ex.requestSize,
+	}
+	if len(requestBody) > 0 {
+		req.PostData = &HARPostData{
+			MimeType: ex.requestHeader.Get("Content-Type"),
+			Text:     string(requestBody),
+		}
+		if !utf8.Valid(requestBody) {
+			req.PostData.Text = base64.StdEncoding.EncodeToString(requestBody)
+			req.PostData.Encoding = "base64"
+		}
+	}
+
+	resp := HARResponse{
+		Status:      ex.status,
+		StatusText:  http.StatusText(ex.status),
+		HTTPVersion: httpVersion(ex.responseProto),
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(ex.responseHeader),
+		Content: HARContent{
+			Size:     ex.responseSize,
+			MimeType: ex.responseHeader.Get("Content-Type"),
+		},
+		HeadersSize: -1,
+		BodySize:    ex.responseSize,
+	}
+	if utf8.Valid(responseBody) {
+		resp.Content.Text = string(responseBody)
+	} else {
+		resp.Content.Text = base64.StdEncoding.EncodeToString(responseBody)
+		resp.Content.Encoding = "base64"
+	}
+
+	return HAREntry{
+		StartedDateTime: ex.started,
+		Time:            milliseconds(ex.wait + ex.receive),
+		Request:         req,
+		Response:        resp,
+		Timings: HARTimings{
+			Wait:    milliseconds(ex.wait),
+			Receive: milliseconds(ex.receive),
+		},
+		Error:     ex.err,
+		Truncated: ex.truncated,
+	}
+}
+
+// harHeaders lists the values of h sorted by header name, redacting the
+// sensitive ones.
+func harHeaders(h http.Header) []HARNameValue {
+	res := []HARNameValue{}
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		sensitive := isSensitiveHeader(name)
+		for _, value := range h[name] {
+			if sensitive {
+				value = redacted
+			}
+			res = append(res, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return res
+}
+
+// isSensitiveHeader reports whether the values of the header name carry
+// credentials or the identity of the caller.
+func isSensitiveHeader(name string) bool {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	return slices.Contains(sensitiveHeaders, name) || strings.HasPrefix(name, sensitiveHeaderPrefix)
+}
+
+// harQueryString lists the query parameters of rawURL.
+func harQueryString(rawURL string) []HARNameValue {
+	res := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return res
+	}
+	query := u.Query()
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[name] {
+			res = append(res, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return res
+}
+
+// redactSecretData replaces the values of the data and stringData of the
+// Secrets carried in body, a request or response body of a call to rawURL:
+// whatever its kind, the body itself, the items of a list and the objects of
+// the rows of a Table. A body that targets Secrets but cannot be decoded
+// (e.g. protobuf or a JSON patch) is replaced as a whole.
+func redactSecretData(rawURL string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return body
+	}
+	info, _, ok := parsePath(u.EscapedPath())
+	if !ok || info.Group != "" || info.Resource != "secrets" {
+		return body
+	}
+
+	obj := map[string]any{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return []byte(redacted)
+	}
+
+	// A patch has no kind, and a Table carries the Secrets in its rows.
+	changed := redactSecret(obj)
+	items, _ := obj["items"].([]any)
+	for _, item := range items {
+		if secret, ok := item.(map[string]any); ok {
+			changed = redactSecret(secret) || changed
+		}
+	}
+	rows, _ := obj["rows"].([]any)
+	for _, row := range rows {
+		r, _ := row.(map[string]any)
+		if secret, ok := r["object"].(map[string]any); ok {
+			changed = redactSecret(secret) || changed
+		}
+	}
+	if !changed {
+		// e.g. a Status: nothing to redact.
+		return body
+	}
+
+	res, err := json.Marshal(obj)
+	if err != nil {
+		return []byte(redacted)
+	}
+	return res
+}
+
+// redactSecret replaces the values of the data and stringData of secret, and
+// tells whether it has any.
+func redactSecret(secret map[string]any) bool {
+	found := false
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := secret[field].(map[string]any)
+		if !ok {
+			continue
+		}
+		for k := range data {
+			data[k] = redacted
+			found = true
+		}
+	}
+	return found
+}
+
+// httpVersion returns the HTTP version of a request or response, defaulting
+// to HTTP/1.1 when the transport did not set it.
+func httpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
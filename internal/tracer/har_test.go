@@ -0,0 +1,252 @@
+package tracer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const secretManifest = `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"creds","namespace":"demo"},"data":{"password":"czNjcjN0"},"stringData":{"token":"t0k3n"}}`
+
+func TestTracerHAR(t *testing.T) {
+	rec := &bodyRecorder{}
+	tracer := (&Tracer{}).WithCapture()
+	tracer.WithRoundTripper(&fixedRoundTripper{
+		code:   http.StatusOK,
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		body:   secretManifest,
+	})
+
+	u, _ := url.Parse("https://kubernetes.default.svc/api/v1/namespaces/demo/secrets/creds")
+	resp, err := tracer.RoundTrip(&http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: http.Header{"Authorization": []string{"Bearer s3cr3t"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != secretManifest {
+		t.Errorf("expected the caller to receive the full body, got %q", b)
+	}
+
+	// Discovery calls are not resource requests but are part of the exchange.
+	tracer.WithRoundTripper(rec)
+	u, _ = url.Parse("https://kubernetes.default.svc/apis?timeout=32s")
+	if _, err := tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u, _ = url.Parse("https://kubernetes.default.svc/api/v1/namespaces/demo/secrets?dryRun=All")
+	if _, err := tracer.RoundTrip(&http.Request{
+		Method: http.MethodPost,
+		URL:    u,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   io.NopCloser(strings.NewReader(secretManifest)),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(rec.body) != secretManifest {
+		t.Errorf("expected the nested RoundTripper to receive the full body, got %q", rec.body)
+	}
+
+	har := tracer.HAR(true)
+	if har.Log.Version != "1.2" {
+		t.Errorf("expected HAR version 1.2, got %q", har.Log.Version)
+	}
+	if len(har.Log.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(har.Log.Entries))
+	}
+
+	get := har.Log.Entries[0]
+	if get.Request.Method != http.MethodGet || get.Request.URL != "https://kubernetes.default.svc/api/v1/namespaces/demo/secrets/creds" {
+		t.Errorf("unexpected request %s %s", get.Request.Method, get.Request.URL)
+	}
+	if get.Response.Status != http.StatusOK || get.Response.StatusText != "OK" {
+		t.Errorf("unexpected response status %d %q", get.Response.Status, get.Response.StatusText)
+	}
+	if len(get.Request.Headers) != 1 || get.Request.Headers[0] != (HARNameValue{Name: "Authorization", Value: redacted}) {
+		t.Errorf("expected the Authorization header to be redacted, got %+v", get.Request.Headers)
+	}
+	assertSecretRedacted(t, get.Response.Content.Text)
+
+	discovery := har.Log.Entries[1]
+	if len(discovery.Request.QueryString) != 1 || discovery.Request.QueryString[0] != (HARNameValue{Name: "timeout", Value: "32s"}) {
+		t.Errorf("unexpected query string %+v", discovery.Request.QueryString)
+	}
+
+	create := har.Log.Entries[2]
+	if create.Request.PostData == nil {
+		t.Fatalf("expected the request body to be captured")
+	}
+	assertSecretRedacted(t, create.Request.PostData.Text)
+
+	raw := tracer.HAR(false)
+	if raw.Log.Entries[0].Request.Headers[0].Value != redacted {
+		t.Errorf("expected the Authorization header to be redacted regardless, got %+v", raw.Log.Entries[0].Request.Headers)
+	}
+	if raw.Log.Entries[0].Response.Content.Text != secretManifest {
+		t.Errorf("expected the Secret to be kept, got %q", raw.Log.Entries[0].Response.Content.Text)
+	}
+}
+
+func TestTracerHARRedactsCredentialHeaders(t *testing.T) {
+	tracer := (&Tracer{}).WithCapture()
+	tracer.WithRoundTripper(&fixedRoundTripper{
+		code:   http.StatusOK,
+		header: http.Header{"Set-Cookie": []string{"session=abc"}},
+		body:   `{}`,
+	})
+
+	u, _ := url.Parse("https://kubernetes.default.svc/api/v1/namespaces/demo/configmaps/settings")
+	tracer.RoundTrip(&http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: http.Header{
+			"Authorization":            []string{"Bearer SUPERSECRET"},
+			"Proxy-Authorization":      []string{"Basic cHJveHk="},
+			"Cookie":                   []string{"session=abc"},
+			"Impersonate-User":         []string{"system:serviceaccount:demo:cdc"},
+			"Impersonate-Group":        []string{"system:serviceaccounts"},
+			"Impersonate-Uid":          []string{"1234"},
+			"Impersonate-Extra-Scopes": []string{"view"},
+			"Accept":                   []string{"application/json"},
+		},
+	})
+
+	for _, redactSecrets := range []bool{true, false} {
+		entry := tracer.HAR(redactSecrets).Log.Entries[0]
+		for _, h := range append(entry.Request.Headers, entry.Response.Headers...) {
+			if h.Name == "Accept" {
+				if h.Value != "application/json" {
+					t.Errorf("expected the Accept header to be kept, got %q", h.Value)
+				}
+				continue
+			}
+			if h.Value != redacted {
+				t.Errorf("redactSecrets=%t: expected the %s header to be redacted, got %q", redactSecrets, h.Name, h.Value)
+			}
+		}
+	}
+}
+
+func TestTracerHARRedactsClientGoBearerToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"demo"}}`)
+	}))
+	defer srv.Close()
+
+	tracer := (&Tracer{}).WithCapture()
+	cfg := &rest.Config{
+		Host:        srv.URL,
+		BearerToken: "SUPERSECRET",
+		Impersonate: rest.ImpersonationConfig{UserName: "system:serviceaccount:demo:cdc"},
+		WrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			return tracer.WithRoundTripper(rt)
+		},
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cs.CoreV1().ConfigMaps("demo").Get(context.Background(), "settings", metav1.GetOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, redactSecrets := range []bool{true, false} {
+		data, err := json.Marshal(tracer.HAR(redactSecrets))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, leak := range []string{"SUPERSECRET", "system:serviceaccount:demo:cdc"} {
+			if strings.Contains(string(data), leak) {
+				t.Errorf("redactSecrets=%t: expected %q to be redacted, got %s", redactSecrets, leak, data)
+			}
+		}
+	}
+}
+
+func TestTracerHARWithoutCapture(t *testing.T) {
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+	u, _ := url.Parse("/api/v1/namespaces/demo/configmaps/settings")
+	tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+
+	har := tracer.HAR(true)
+	if har.Log.Entries == nil || len(har.Log.Entries) != 0 {
+		t.Errorf("expected no entries, got %+v", har.Log.Entries)
+	}
+	if len(tracer.GetResources()) != 1 {
+		t.Errorf("expected the call to be traced")
+	}
+}
+
+func TestRedactSecretData(t *testing.T) {
+	list := `{"kind":"SecretList","items":[{"kind":"Secret","data":{"a":"YQ=="}},{"kind":"Secret","data":{"b":"Yg=="}}]}`
+	got := redactSecretData("/api/v1/namespaces/demo/secrets", []byte(list))
+	expected := `{"items":[{"data":{"a":"REDACTED"},"kind":"Secret"},{"data":{"b":"REDACTED"},"kind":"Secret"}],"kind":"SecretList"}`
+	if string(got) != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	patch := `{"data":{"password":"cGFzc3dvcmQ="},"stringData":{"token":"t0k3n"}}`
+	got = redactSecretData("/api/v1/namespaces/demo/secrets/creds", []byte(patch))
+	expected = `{"data":{"password":"REDACTED"},"stringData":{"token":"REDACTED"}}`
+	if string(got) != expected {
+		t.Errorf("expected a patch to be redacted as %s, got %s", expected, got)
+	}
+
+	table := `{"kind":"Table","rows":[{"cells":["creds"],"object":{"kind":"Secret","data":{"a":"YQ=="}}}]}`
+	got = redactSecretData("/api/v1/namespaces/demo/secrets", []byte(table))
+	expected = `{"kind":"Table","rows":[{"cells":["creds"],"object":{"data":{"a":"REDACTED"},"kind":"Secret"}}]}`
+	if string(got) != expected {
+		t.Errorf("expected a Table to be redacted as %s, got %s", expected, got)
+	}
+
+	jsonPatch := `[{"op":"replace","path":"/data/password","value":"cGFzc3dvcmQ="}]`
+	if got := redactSecretData("/api/v1/namespaces/demo/secrets/creds", []byte(jsonPatch)); string(got) != redacted {
+		t.Errorf("expected a JSON patch to be redacted, got %s", got)
+	}
+
+	status := `{"kind":"Status","message":"secrets \"creds\" not found","code":404}`
+	if got := redactSecretData("/api/v1/namespaces/demo/secrets/creds", []byte(status)); string(got) != status {
+		t.Errorf("expected a Status to be kept, got %s", got)
+	}
+
+	if got := redactSecretData("/api/v1/namespaces/demo/secrets/creds", []byte{0x6b, 0x38, 0x73, 0x00}); string(got) != redacted {
+		t.Errorf("expected an undecodable Secret to be redacted, got %q", got)
+	}
+
+	configMap := `{"kind":"ConfigMap","data":{"a":"b"}}`
+	if got := redactSecretData("/api/v1/namespaces/demo/configmaps/settings", []byte(configMap)); string(got) != configMap {
+		t.Errorf("expected a ConfigMap to be kept, got %s", got)
+	}
+}
+
+func assertSecretRedacted(t *testing.T, text string) {
+	t.Helper()
+
+	secret := struct {
+		Data       map[string]string `json:"data"`
+		StringData map[string]string `json:"stringData"`
+	}{}
+	if err := json.Unmarshal([]byte(text), &secret); err != nil {
+		t.Fatalf("unexpected error decoding %q: %v", text, err)
+	}
+	if secret.Data["password"] != redacted || secret.StringData["token"] != redacted {
+		t.Errorf("expected the Secret data to be redacted, got %s", text)
+	}
+}
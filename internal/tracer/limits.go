@@ -0,0 +1,50 @@
+package tracer
+
+// Limits bound what a tracer keeps in memory during an inspection. Calls past
+// the limits are still forwarded to the API server, so the dry-run is not
+// affected, but are not recorded, and the tracer reports itself as
+// truncated. A zero value means no limit.
+type Limits struct {
+	// MaxCalls is the maximum number of calls recorded.
+	MaxCalls int
+	// MaxBodyBytes is the maximum number of request and response body bytes
+	// kept by a tracer built WithCapture. The exchanges past the limit are
+	// kept without their bodies.
+	MaxBodyBytes int
+}
+
+// WithLimits bounds what the tracer keeps. See Limits.
+func (t *Tracer) WithLimits(limits Limits) *Tracer {
+	t.limits = limits
+	return t
+}
+
+// Truncated reports whether the tracer dropped calls or bodies because it
+// reached its limits.
+func (t *Tracer) Truncated() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.truncated
+}
+
+// callsFull reports whether n recorded calls reach the MaxCalls limit, marking
+// the tracer as truncated if so. It must be called with t.mu held.
+func (t *Tracer) callsFull(n int) bool {
+	if t.limits.MaxCalls > 0 && n >= t.limits.MaxCalls {
+		t.truncated = true
+		return true
+	}
+	return false
+}
+
+// reserveBodyBytes accounts for n more body bytes, reporting false and marking
+// the tracer as truncated when they would exceed the MaxBodyBytes limit. It
+// must be called with t.mu held.
+func (t *Tracer) reserveBodyBytes(n int) bool {
+	if t.limits.MaxBodyBytes > 0 && t.bodyBytes+n > t.limits.MaxBodyBytes {
+		t.truncated = true
+		return false
+	}
+	t.bodyBytes += n
+	return true
+}
@@ -0,0 +1,91 @@
+package tracer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTracerMaxCalls(t *testing.T) {
+	tracer := (&Tracer{}).WithCapture().WithLimits(Limits{MaxCalls: 3})
+	tracer.WithRoundTripper(&fixedRoundTripper{code: http.StatusOK, body: "{}"})
+
+	for i := range 5 {
+		u, _ := url.Parse(fmt.Sprintf("/api/v1/namespaces/demo/configmaps/cm-%d", i))
+		resp, err := tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}})
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected call %d past the limit to be forwarded, got %v, %v", i, resp, err)
+		}
+	}
+
+	if got := len(tracer.GetResources()); got != 3 {
+		t.Errorf("expected 3 recorded calls, got %d", got)
+	}
+	if got := len(tracer.HAR(true).Log.Entries); got != 3 {
+		t.Errorf("expected 3 captured exchanges, got %d", got)
+	}
+	if !tracer.Truncated() {
+		t.Errorf("expected the tracer to be truncated")
+	}
+	if tracer.HAR(true).Log.Comment == "" {
+		t.Errorf("expected the HAR to say it was truncated")
+	}
+}
+
+func TestTracerMaxBodyBytes(t *testing.T) {
+	body := strings.Repeat("x", 40)
+	tracer := (&Tracer{}).WithCapture().WithLimits(Limits{MaxBodyBytes: 100})
+	tracer.WithRoundTripper(&fixedRoundTripper{code: http.StatusOK, body: body})
+
+	for range 3 {
+		u, _ := url.Parse("/api/v1/namespaces/demo/configmaps/settings")
+		resp, err := tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// The caller always receives the whole body.
+		if b, _ := io.ReadAll(resp.Body); string(b) != body {
+			t.Errorf("expected the full body, got %q", b)
+		}
+	}
+
+	entries := tracer.HAR(true).Log.Entries
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 exchanges, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		truncated := i == 2
+		if entry.Truncated != truncated {
+			t.Errorf("entry %d: expected truncated to be %v", i, truncated)
+		}
+		if (entry.Response.Content.Text == "") != truncated {
+			t.Errorf("entry %d: unexpected body %q", i, entry.Response.Content.Text)
+		}
+		if entry.Response.BodySize != len(body) {
+			t.Errorf("entry %d: expected body size %d, got %d", i, len(body), entry.Response.BodySize)
+		}
+	}
+	if !tracer.Truncated() {
+		t.Errorf("expected the tracer to be truncated")
+	}
+	if got := len(tracer.GetResources()); got != 3 {
+		t.Errorf("expected the calls to be recorded regardless of their bodies, got %d", got)
+	}
+}
+
+func TestTracerWithinLimits(t *testing.T) {
+	tracer := (&Tracer{}).WithLimits(Limits{MaxCalls: 2, MaxBodyBytes: 10})
+	tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+	for range 2 {
+		u, _ := url.Parse("/api/v1/namespaces/demo/configmaps/settings")
+		tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+	}
+
+	if tracer.Truncated() {
+		t.Errorf("expected the tracer not to be truncated")
+	}
+}
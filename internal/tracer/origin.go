@@ -0,0 +1,85 @@
+package tracer
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// helmReleasePrefix is the name prefix of the Secrets (or ConfigMaps) Helm
+// stores releases in.
+const helmReleasePrefix = "sh.helm.release.v1."
+
+// discoveryResources are the resources read to discover what the API server
+// serves, rather than as chart dependencies.
+var discoveryResources = []string{
+	"apiextensions.k8s.io/customresourcedefinitions",
+	"apiregistration.k8s.io/apiservices",
+}
+
+// classify returns the origin category of a traced call on res.
+func (t *Tracer) classify(req *http.Request, res *resources.Resource) string {
+	if t.isHelmBookkeeping(req, res) {
+		return resources.OriginHelm
+	}
+
+	if res.Group == "authorization.k8s.io" || slices.Contains(discoveryResources, res.Group+"/"+res.Resource) {
+		return resources.OriginDiscovery
+	}
+
+	for _, verb := range res.Verbs {
+		switch verb {
+		case "get", "list", "watch":
+		default:
+			return resources.OriginRendered
+		}
+	}
+
+	return resources.OriginLookup
+}
+
+func (t *Tracer) isHelmBookkeeping(req *http.Request, res *resources.Resource) bool {
+	if res.Group != "" {
+		return false
+	}
+
+	switch res.Resource {
+	case "secrets", "configmaps":
+		if res.Name != "" {
+			prefix := helmReleasePrefix
+			if t.releaseName != "" {
+				prefix += t.releaseName + ".v"
+			}
+			return strings.HasPrefix(res.Name, prefix)
+		}
+
+		// Release history is listed by label.
+		for _, sel := range strings.Split(req.URL.Query().Get("labelSelector"), ",") {
+			if strings.TrimSpace(sel) == "owner=helm" {
+				return true
+			}
+		}
+	case "namespaces":
+		return t.releaseNamespace != "" && res.Name == t.releaseNamespace
+	}
+
+	return false
+}
+
+// originRank orders origins so that an aggregated entry reports the most
+// significant one: an object that is both looked up and written is rendered.
+func originRank(origin string) int {
+	switch origin {
+	case resources.OriginHelm:
+		return 4
+	case resources.OriginRendered:
+		return 3
+	case resources.OriginLookup:
+		return 2
+	case resources.OriginDiscovery:
+		return 1
+	}
+	return 0
+}
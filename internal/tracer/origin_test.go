@@ -0,0 +1,122 @@
+package tracer
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+func TestTracerClassifiesOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "dry-run create",
+			method:   http.MethodPost,
+			path:     "/apis/apps/v1/namespaces/demo/deployments?dryRun=All",
+			body:     `{"kind":"Deployment","metadata":{"name":"web"}}`,
+			expected: resources.OriginRendered,
+		},
+		{
+			name:     "lookup",
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/demo/configmaps/settings",
+			expected: resources.OriginLookup,
+		},
+		{
+			name:     "lookup list",
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/demo/secrets",
+			expected: resources.OriginLookup,
+		},
+		{
+			name:     "crd validation",
+			method:   http.MethodGet,
+			path:     "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/foos.example.com",
+			expected: resources.OriginDiscovery,
+		},
+		{
+			name:     "access review",
+			method:   http.MethodPost,
+			path:     "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews",
+			body:     `{"kind":"SelfSubjectAccessReview"}`,
+			expected: resources.OriginDiscovery,
+		},
+		{
+			name:     "release history",
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/demo/secrets?labelSelector=name%3Dweb%2Cowner%3Dhelm",
+			expected: resources.OriginHelm,
+		},
+		{
+			name:     "release secret",
+			method:   http.MethodPost,
+			path:     "/api/v1/namespaces/demo/secrets",
+			body:     `{"kind":"Secret","metadata":{"name":"sh.helm.release.v1.web.v1"}}`,
+			expected: resources.OriginHelm,
+		},
+		{
+			name:     "other release secret",
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/demo/secrets/sh.helm.release.v1.other.v1",
+			expected: resources.OriginLookup,
+		},
+		{
+			name:     "release namespace",
+			method:   http.MethodPost,
+			path:     "/api/v1/namespaces",
+			body:     `{"kind":"Namespace","metadata":{"name":"demo"}}`,
+			expected: resources.OriginHelm,
+		},
+		{
+			name:     "rendered namespace",
+			method:   http.MethodPost,
+			path:     "/api/v1/namespaces",
+			body:     `{"kind":"Namespace","metadata":{"name":"team-a"}}`,
+			expected: resources.OriginRendered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := (&Tracer{}).WithRelease("web", "demo")
+			tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+			u, _ := url.Parse(tt.path)
+			req := &http.Request{Method: tt.method, URL: u, Header: http.Header{}}
+			if tt.body != "" {
+				req.Body = io.NopCloser(strings.NewReader(tt.body))
+			}
+			tracer.RoundTrip(req)
+
+			got := tracer.GetResources()
+			if len(got) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(got))
+			}
+			if got[0].Origin != tt.expected {
+				t.Errorf("expected origin %q, got %q", tt.expected, got[0].Origin)
+			}
+		})
+	}
+}
+
+func TestTracerClassifiesReleaseStorageWithoutRelease(t *testing.T) {
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+	u, _ := url.Parse("/api/v1/namespaces/demo/secrets/sh.helm.release.v1.web.v1")
+	tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+
+	got := tracer.GetResources()
+	if len(got) != 1 || got[0].Origin != resources.OriginHelm {
+		t.Errorf("expected a helm entry, got %+v", got)
+	}
+}
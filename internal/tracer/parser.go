@@ -0,0 +1,166 @@
+package tracer
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// RequestInfo is the API resource targeted by a request.
+type RequestInfo struct {
+	Group       string
+	Version     string
+	Resource    string
+	Subresource string
+	Namespace   string
+	Name        string
+	// Verb is the RBAC verb the request is authorized against. When empty,
+	// the tracer derives it from the HTTP method, see DefaultParser.
+	Verb string
+}
+
+// Parser extracts the API resource targeted by a request. Parse returns false
+// when the request is not a resource request it understands (e.g. a discovery
+// call), so that the next parser is consulted.
+type Parser interface {
+	Parse(req *http.Request) (*RequestInfo, bool)
+}
+
+// ParserFunc adapts a function to the Parser interface.
+type ParserFunc func(req *http.Request) (*RequestInfo, bool)
+
+func (f ParserFunc) Parse(req *http.Request) (*RequestInfo, bool) {
+	return f(req)
+}
+
+// DefaultParser parses the paths served by the Kubernetes API server and by
+// the aggregated API servers behind it, following the semantics of the
+// apiserver RequestInfoFactory. It understands the legacy core group
+// (/api/v1/...), named groups (/apis/<group>/<version>/...), the deprecated
+// /watch/ prefix, and both namespaced and cluster-scoped paths pointing at a
+// collection, at a named object or at one of its subresources, at any depth:
+//
+//	/api/v1/nodes
+//	/api/v1/namespaces/<namespace>
+//	/apis/<group>/<version>/<resource>/<name>
+//	/apis/<group>/<version>/namespaces/<namespace>/<resource>
+//	/apis/<group>/<version>/namespaces/<namespace>/<resource>/<name>/<subresource>
+//	/api/v1/namespaces/<namespace>/services/<name>/proxy/<path...>
+//	/apis/<group>/<version>/watch/namespaces/<namespace>/<resource>/<name>
+//
+// Path segments are unescaped after splitting the escaped path, so a name
+// containing an escaped slash is kept whole. Discovery paths such as /api/v1
+// or /apis/<group>/<version> are not resource requests and are rejected.
+var DefaultParser Parser = ParserFunc(parseRequest)
+
+func parseRequest(req *http.Request) (*RequestInfo, bool) {
+	info, watchPrefix, ok := parsePath(req.URL.EscapedPath())
+	if !ok {
+		return nil, false
+	}
+
+	info.Verb = requestVerb(req, info.Name, watchPrefix)
+	return info, true
+}
+
+// namespaceSubresources are the subresources of the Namespace object itself,
+// which must not be mistaken for a resource inside the namespace.
+var namespaceSubresources = []string{"status", "finalize"}
+
+// parsePath extracts the resource targeted by an escaped API path, see
+// DefaultParser, and reports whether the path uses the /watch/ prefix. The
+// Verb of the result is left empty.
+func parsePath(escapedPath string) (info *RequestInfo, watchPrefix bool, ok bool) {
+	parts := strings.Split(strings.Trim(escapedPath, "/"), "/")
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, false, false
+		}
+		parts[i] = unescaped
+	}
+
+	info = &RequestInfo{}
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		info.Version = parts[1]
+		parts = parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		info.Group = parts[1]
+		info.Version = parts[2]
+		parts = parts[3:]
+	default:
+		return nil, false, false
+	}
+
+	if parts[0] == "watch" {
+		watchPrefix = true
+		parts = parts[1:]
+	}
+
+	if len(parts) >= 3 && parts[0] == "namespaces" && !slices.Contains(namespaceSubresources, parts[2]) {
+		info.Namespace = parts[1]
+		parts = parts[2:]
+	}
+
+	// Anything after the subresource (e.g. the target path of a proxy call)
+	// is not part of the resource identity.
+	if len(parts) == 0 {
+		return nil, false, false
+	}
+	info.Resource = parts[0]
+	if len(parts) > 1 {
+		info.Name = parts[1]
+	}
+	if len(parts) > 2 {
+		info.Subresource = parts[2]
+	}
+
+	if info.Resource == "" {
+		return nil, false, false
+	}
+
+	return info, watchPrefix, true
+}
+
+// requestVerb maps the HTTP method of req to the Kubernetes RBAC verb the API
+// server would authorize it against, following the same rules as the
+// apiserver RequestInfoFactory: a GET without a name is a list, a GET (or a
+// list) with watch=true or the /watch/ path prefix is a watch and a DELETE
+// without a name is a deletecollection.
+func requestVerb(req *http.Request, name string, watchPrefix bool) string {
+	verb := strings.ToLower(req.Method)
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead:
+		verb = "get"
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodPut:
+		verb = "update"
+	}
+
+	switch verb {
+	case "get":
+		if name == "" {
+			verb = "list"
+		}
+		if watchPrefix || isWatch(req) {
+			verb = "watch"
+		}
+	case "delete":
+		if name == "" {
+			verb = "deletecollection"
+		}
+	}
+
+	return verb
+}
+
+func isWatch(req *http.Request) bool {
+	switch strings.ToLower(req.URL.Query().Get("watch")) {
+	case "true", "1":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package tracer
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		expected *RequestInfo
+	}{
+		{
+			name:     "aggregated api collection",
+			url:      "/apis/metrics.k8s.io/v1beta1/namespaces/demo/pods",
+			expected: &RequestInfo{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods", Namespace: "demo", Verb: "list"},
+		},
+		{
+			name:     "aggregated api object",
+			url:      "/apis/metrics.k8s.io/v1beta1/nodes/worker-1",
+			expected: &RequestInfo{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes", Name: "worker-1", Verb: "get"},
+		},
+		{
+			name:     "escaped name",
+			url:      "/api/v1/namespaces/demo/configmaps/a%2Fb",
+			expected: &RequestInfo{Version: "v1", Resource: "configmaps", Namespace: "demo", Name: "a/b", Verb: "get"},
+		},
+		{
+			name:     "escaped colon",
+			url:      "/apis/rbac.authorization.k8s.io/v1/clusterroles/system%3Aaggregate-to-view",
+			expected: &RequestInfo{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "system:aggregate-to-view", Verb: "get"},
+		},
+		{
+			name:     "watch prefix",
+			url:      "/api/v1/watch/namespaces/demo/pods",
+			expected: &RequestInfo{Version: "v1", Resource: "pods", Namespace: "demo", Verb: "watch"},
+		},
+		{
+			name:     "dry-run create",
+			method:   http.MethodPost,
+			url:      "/apis/apps/v1/namespaces/demo/deployments?dryRun=All",
+			expected: &RequestInfo{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "demo", Verb: "create"},
+		},
+		{name: "aggregated api discovery", url: "/apis/metrics.k8s.io/v1beta1"},
+		{name: "group discovery", url: "/apis/metrics.k8s.io"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			got, ok := DefaultParser.Parse(&http.Request{Method: tt.method, URL: u})
+			if ok != (tt.expected != nil) {
+				t.Fatalf("expected ok to be %v, got %v", tt.expected != nil, ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// clusterParser parses the paths of a multi-cluster proxy, which prefixes the
+// API paths with /clusters/<cluster>.
+var clusterParser = ParserFunc(func(req *http.Request) (*RequestInfo, bool) {
+	rest, ok := strings.CutPrefix(req.URL.Path, "/clusters/")
+	if !ok {
+		return nil, false
+	}
+	_, path, ok := strings.Cut(rest, "/")
+	if !ok {
+		return nil, false
+	}
+
+	inner := req.Clone(req.Context())
+	inner.URL = &url.URL{Path: "/" + path, RawQuery: req.URL.RawQuery}
+	return DefaultParser.Parse(inner)
+})
+
+func TestTracerCustomParsers(t *testing.T) {
+	// A parser for an extension API whose paths do not follow the API
+	// conventions, leaving the verb to the tracer.
+	metricsParser := ParserFunc(func(req *http.Request) (*RequestInfo, bool) {
+		name, ok := strings.CutPrefix(req.URL.Path, "/metrics/v1/series/")
+		if !ok {
+			return nil, false
+		}
+		return &RequestInfo{Group: "metrics.example.io", Version: "v1", Resource: "series", Name: name}, true
+	})
+
+	tracer := (&Tracer{}).WithParser(clusterParser).WithParser(metricsParser)
+	tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+	tests := []struct {
+		method   string
+		path     string
+		expected []string
+	}{
+		{method: http.MethodGet, path: "/clusters/east/apis/apps/v1/namespaces/demo/deployments/web", expected: []string{"apps", "v1", "deployments", "demo", "web", "get"}},
+		{method: http.MethodDelete, path: "/metrics/v1/series/latency", expected: []string{"metrics.example.io", "v1", "series", "", "latency", "delete"}},
+		{method: http.MethodGet, path: "/api/v1/namespaces/demo/pods", expected: []string{"", "v1", "pods", "demo", "", "list"}},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.path)
+		tracer.RoundTrip(&http.Request{Method: tt.method, URL: u, Header: http.Header{}})
+	}
+
+	got := tracer.GetResources()
+	if len(got) != len(tests) {
+		t.Fatalf("expected %d resources, got %d", len(tests), len(got))
+	}
+	for i, tt := range tests {
+		r := got[i]
+		if fields := []string{r.Group, r.Version, r.Resource, r.Namespace, r.Name, r.Verbs[0]}; !reflect.DeepEqual(fields, tt.expected) {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.expected, fields)
+		}
+	}
+}
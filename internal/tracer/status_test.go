@@ -0,0 +1,61 @@
+package tracer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// statusRoundTripper is a mock RoundTripper answering every call with a fixed
+// status code, or failing with err when it is set.
+type statusRoundTripper struct {
+	code int
+	err  error
+}
+
+func (rt *statusRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.err != nil {
+		return nil, rt.err
+	}
+	return &http.Response{
+		StatusCode: rt.code,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+func TestTracerRecordsStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		rt       http.RoundTripper
+		expected int
+	}{
+		{name: "ok", rt: &statusRoundTripper{code: http.StatusOK}, expected: http.StatusOK},
+		{name: "not found", rt: &statusRoundTripper{code: http.StatusNotFound}, expected: http.StatusNotFound},
+		{name: "forbidden", rt: &statusRoundTripper{code: http.StatusForbidden}, expected: http.StatusForbidden},
+		{name: "conflict", rt: &statusRoundTripper{code: http.StatusConflict}, expected: http.StatusConflict},
+		{name: "transport error", rt: &statusRoundTripper{err: errors.New("connection refused")}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := &Tracer{}
+			tracer.WithRoundTripper(tt.rt)
+
+			u, _ := url.Parse("/api/v1/namespaces/demo/configmaps/settings")
+			tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+
+			got := tracer.GetResources()
+			if len(got) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(got))
+			}
+			if got[0].Status != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, got[0].Status)
+			}
+		})
+	}
+}
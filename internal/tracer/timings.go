@@ -0,0 +1,41 @@
+package tracer
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// GetCalls returns the timing of every call made through the tracer,
+// discovery calls included, in the order they were made.
+func (t *Tracer) GetCalls() []resources.Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.calls)
+}
+
+// recordCall records the timing of a call started at start.
+func (t *Tracer) recordCall(req *http.Request, start time.Time, verb, origin string, resp *http.Response) {
+	call := resources.Call{
+		Method:     req.Method,
+		URI:        req.URL.RequestURI(),
+		Verb:       verb,
+		Origin:     origin,
+		Start:      start.UTC().Format(time.RFC3339Nano),
+		DurationMs: milliseconds(time.Since(start)),
+	}
+	if call.Method == "" {
+		call.Method = http.MethodGet
+	}
+	if resp != nil {
+		call.Status = resp.StatusCode
+	}
+
+	t.mu.Lock()
+	if !t.callsFull(len(t.calls)) {
+		t.calls = append(t.calls, call)
+	}
+	t.mu.Unlock()
+}
@@ -0,0 +1,57 @@
+package tracer
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
+)
+
+// slowRoundTripper is a mock RoundTripper taking delay to answer.
+type slowRoundTripper struct {
+	delay time.Duration
+}
+
+func (rt *slowRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(rt.delay)
+	return (&NoOpRoundTripper{}).RoundTrip(req)
+}
+
+func TestTracerRecordsCalls(t *testing.T) {
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(&slowRoundTripper{delay: 5 * time.Millisecond})
+
+	for _, path := range []string{"/apis", "/api/v1/namespaces/demo/configmaps/settings?resourceVersion=0"} {
+		u, _ := url.Parse(path)
+		tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+	}
+
+	calls := tracer.GetCalls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+
+	expected := []resources.Call{
+		{Method: http.MethodGet, URI: "/apis", Origin: resources.OriginDiscovery, Status: http.StatusOK},
+		{Method: http.MethodGet, URI: "/api/v1/namespaces/demo/configmaps/settings?resourceVersion=0", Verb: "get", Origin: resources.OriginLookup, Status: http.StatusOK},
+	}
+	for i, call := range calls {
+		if call.DurationMs < 5 {
+			t.Errorf("call %d: expected a duration of at least 5ms, got %v", i, call.DurationMs)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, call.Start); err != nil {
+			t.Errorf("call %d: invalid start %q: %v", i, call.Start, err)
+		}
+		call.DurationMs, call.Start = 0, ""
+		if call != expected[i] {
+			t.Errorf("call %d: expected %+v, got %+v", i, expected[i], call)
+		}
+	}
+
+	// Discovery calls are timed but are not resources.
+	if got := len(tracer.GetResources()); got != 1 {
+		t.Errorf("expected 1 resource, got %d", got)
+	}
+}
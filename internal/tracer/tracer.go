@@ -2,8 +2,9 @@ package tracer
 
 import (
 	"net/http"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/krateoplatformops/chart-inspector/internal/handlers/resources"
 )
@@ -15,6 +16,20 @@ type Tracer struct {
 	http.RoundTripper
 	mu        sync.Mutex
 	resources []resources.Resource
+
+	releaseName      string
+	releaseNamespace string
+
+	capture   bool
+	exchanges []exchange
+
+	parsers []Parser
+
+	calls []resources.Call
+
+	limits    Limits
+	truncated bool
+	bodyBytes int
 }
 
 func (t *Tracer) GetResources() []resources.Resource {
@@ -23,70 +38,180 @@ func (t *Tracer) GetResources() []resources.Resource {
 	// Return a copy to prevent external modification
 	resCopy := make([]resources.Resource, len(t.resources))
 	copy(resCopy, t.resources)
+	for i := range resCopy {
+		resCopy[i].Verbs = slices.Clone(resCopy[i].Verbs)
+		resCopy[i].Warnings = slices.Clone(resCopy[i].Warnings)
+	}
 	return resCopy
 }
 
+// GetAggregatedResources returns the traced resources with identical
+// (group, version, resource, subresource, namespace, name) tuples collapsed
+// into a single entry. See Aggregate.
+func (t *Tracer) GetAggregatedResources() []resources.Resource {
+	return Aggregate(t.GetResources())
+}
+
+// Aggregate collapses the entries of li that refer to the same
+// (group, version, resource, subresource, namespace, name) tuple. The
+// resulting entry carries the sorted union of their verbs, the status of the
+// last call and, in Count, the number of calls that touched the object.
+// Entries keep the order in which they were first seen.
+func Aggregate(li []resources.Resource) []resources.Resource {
+	type key struct {
+		group, version, resource, subresource, namespace, name string
+	}
+
+	res := []resources.Resource{}
+	index := map[key]int{}
+	for _, el := range li {
+		k := key{el.Group, el.Version, el.Resource, el.Subresource, el.Namespace, el.Name}
+
+		count := el.Count
+		if count == 0 {
+			count = 1
+		}
+
+		i, ok := index[k]
+		if !ok {
+			i = len(res)
+			index[k] = i
+
+			first := el
+			first.Verbs = nil
+			first.Warnings = nil
+			first.Count = 0
+			res = append(res, first)
+		}
+
+		agg := &res[i]
+		agg.Count += count
+		if agg.Kind == "" {
+			agg.Kind = el.Kind
+		}
+		if agg.Namespaced == nil {
+			agg.Namespaced = el.Namespaced
+		}
+		if agg.Source == "" {
+			agg.Source, agg.Template, agg.Subchart = el.Source, el.Template, el.Subchart
+		}
+		if originRank(el.Origin) > originRank(agg.Origin) {
+			agg.Origin = el.Origin
+		}
+		if el.Status != 0 {
+			agg.Status = el.Status
+		}
+		for _, warning := range el.Warnings {
+			if !slices.Contains(agg.Warnings, warning) {
+				agg.Warnings = append(agg.Warnings, warning)
+			}
+		}
+		if el.Admission != "" {
+			agg.Admission = el.Admission
+		}
+		for _, verb := range el.Verbs {
+			if !slices.Contains(agg.Verbs, verb) {
+				agg.Verbs = append(agg.Verbs, verb)
+			}
+		}
+	}
+
+	for i := range res {
+		slices.Sort(res[i].Verbs)
+	}
+
+	return res
+}
+
 func (t *Tracer) WithRoundTripper(rt http.RoundTripper) *Tracer {
 	t.RoundTripper = rt
 	return t
 }
 
+// WithParser registers a custom Parser for requests the default one does not
+// understand, e.g. the paths of an extension API server. Custom parsers are
+// consulted in the order they were registered, before DefaultParser.
+func (t *Tracer) WithParser(p Parser) *Tracer {
+	t.parsers = append(t.parsers, p)
+	return t
+}
+
+// WithRelease tells the tracer the name and namespace of the Helm release
+// being installed, so that calls on its release storage and on its Namespace
+// are classified as Helm bookkeeping.
+func (t *Tracer) WithRelease(name, namespace string) *Tracer {
+	t.releaseName = name
+	t.releaseNamespace = namespace
+	return t
+}
+
 // RoundTrip calls the nested RoundTripper while printing each request and
 // response/error to t.OutFile on either side of the nested call.  WARNING: this
 // may output sensitive information including bearer tokens.
 func (t *Tracer) RoundTrip(req *http.Request) (*http.Response, error) {
-	split := strings.Split(req.URL.Path, "/")
+	info, ok := t.parse(req)
+	if !ok {
+		// Not a resource request: API discovery.
+		start := time.Now()
+		resp, err := t.roundTrip(req, isWatch(req))
+		t.recordCall(req, start, "", resources.OriginDiscovery, resp)
+		return resp, err
+	}
 
-	// Capture resource metadata under mutex protection
-	if len(split) > 2 {
-		var resource *resources.Resource
-
-		if len(split) == 8 && (split[1] == "apis" || split[1] == "api") && split[4] == "namespaces" {
-			resource = &resources.Resource{
-				Group:     split[2],
-				Version:   split[3],
-				Resource:  split[6],
-				Namespace: split[5],
-				Name:      split[7],
-			}
-		} else if len(split) == 7 && (split[1] == "apis" || split[1] == "api") && split[3] == "namespaces" {
-			resource = &resources.Resource{
-				Group:     "",
-				Version:   split[2],
-				Resource:  split[5],
-				Namespace: split[4],
-				Name:      split[6],
-			}
-		} else if len(split) == 6 && (split[1] == "apis" || split[1] == "api") {
-			resource = &resources.Resource{
-				Group:     split[2],
-				Version:   split[3],
-				Resource:  split[4],
-				Namespace: "",
-				Name:      split[5],
-			}
-		} else if len(split) == 5 && (split[1] == "apis" || split[1] == "api") {
-			resource = &resources.Resource{
-				Group:     "",
-				Version:   split[2],
-				Resource:  split[3],
-				Namespace: "",
-				Name:      split[4],
-			}
-		}
+	verb := info.Verb
+	if verb == "" {
+		verb = requestVerb(req, info.Name, false)
+	}
+	resource := resources.Resource{
+		Group:       info.Group,
+		Version:     info.Version,
+		Resource:    info.Resource,
+		Subresource: info.Subresource,
+		Namespace:   info.Namespace,
+		Name:        info.Name,
+		Verbs:       []string{verb},
+	}
 
-		if resource != nil {
-			t.mu.Lock()
-			t.resources = append(t.resources, *resource)
-			t.mu.Unlock()
+	if carriesObject(req, info) {
+		var obj *object
+		var err error
+		req, obj, err = teeRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			applyObject(&resource, obj)
 		}
 	}
+	resource.Origin = t.classify(req, &resource)
 
 	// Call the nested RoundTripper.
-	resp, err := t.RoundTripper.RoundTrip(req)
-	if err != nil {
-		return resp, err
+	start := time.Now()
+	resp, err := t.roundTrip(req, verb == "watch")
+	t.recordCall(req, start, verb, resource.Origin, resp)
+	if resp != nil {
+		resource.Status = resp.StatusCode
+		resource.Warnings = responseWarnings(resp)
+		resource.Admission = admissionRejection(resp, verb)
+	}
+
+	// Capture resource metadata under mutex protection
+	t.mu.Lock()
+	if !t.callsFull(len(t.resources)) {
+		t.resources = append(t.resources, resource)
 	}
+	t.mu.Unlock()
 
 	return resp, err
 }
+
+// parse extracts the resource targeted by req with the first parser that
+// understands it.
+func (t *Tracer) parse(req *http.Request) (*RequestInfo, bool) {
+	for _, p := range t.parsers {
+		if info, ok := p.Parse(req); ok {
+			return info, true
+		}
+	}
+	return DefaultParser.Parse(req)
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -64,6 +65,9 @@ func TestTracerMultiplePaths(t *testing.T) {
 				Resource:  "deployments",
 				Namespace: "default",
 				Name:      "my-dep",
+				Verbs:     []string{"get"},
+				Origin:    resources.OriginLookup,
+				Status:    http.StatusOK,
 			},
 		},
 		{
@@ -74,6 +78,9 @@ func TestTracerMultiplePaths(t *testing.T) {
 				Resource:  "services",
 				Namespace: "kube-system",
 				Name:      "kube-dns",
+				Verbs:     []string{"get"},
+				Origin:    resources.OriginLookup,
+				Status:    http.StatusOK,
 			},
 		},
 		{
@@ -84,6 +91,9 @@ func TestTracerMultiplePaths(t *testing.T) {
 				Resource:  "jobs",
 				Namespace: "",
 				Name:      "my-job",
+				Verbs:     []string{"get"},
+				Origin:    resources.OriginLookup,
+				Status:    http.StatusOK,
 			},
 		},
 	}
@@ -102,7 +112,7 @@ func TestTracerMultiplePaths(t *testing.T) {
 	}
 
 	for i, p := range paths {
-		if resources[i] != p.expected {
+		if !reflect.DeepEqual(resources[i], p.expected) {
 			t.Errorf("resource %d: expected %+v, got %+v", i, p.expected, resources[i])
 		}
 	}
@@ -216,7 +226,7 @@ func TestTracerGetResourcesReturnsDeepCopy(t *testing.T) {
 		t.Fatalf("expected same resource count, got %d vs %d", len(resources1), len(resources2))
 	}
 
-	if resources1[0] != resources2[0] {
+	if !reflect.DeepEqual(resources1[0], resources2[0]) {
 		t.Errorf("expected equal resources: %+v vs %+v", resources1[0], resources2[0])
 	}
 
@@ -237,6 +247,10 @@ func TestTracerInvalidPaths(t *testing.T) {
 		"/",
 		"/notapis/v1/pods",
 		"/apis/v1",
+		"/apis/apps/v1",
+		"/api/v1",
+		"/api/v1/watch",
+		"/openapi/v2",
 		"",
 	}
 
@@ -254,3 +268,335 @@ func TestTracerInvalidPaths(t *testing.T) {
 		t.Fatalf("expected 0 resources for invalid paths, got %d", len(resources))
 	}
 }
+
+func TestTracerRecordsVerbs(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{method: http.MethodGet, path: "/apis/apps/v1/namespaces/default/deployments/dep", expected: "get"},
+		{method: http.MethodHead, path: "/apis/apps/v1/namespaces/default/deployments/dep", expected: "get"},
+		{method: http.MethodGet, path: "/apis/apps/v1/namespaces/default/deployments/dep?watch=true", expected: "watch"},
+		{method: http.MethodPut, path: "/apis/apps/v1/namespaces/default/deployments/dep", expected: "update"},
+		{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/default/deployments/dep", expected: "patch"},
+		{method: http.MethodDelete, path: "/apis/apps/v1/namespaces/default/deployments/dep", expected: "delete"},
+		{method: http.MethodPost, path: "/api/v1/namespaces/default/pods/my-pod", expected: "create"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			tracer := &Tracer{}
+			tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+			u, _ := url.Parse(tt.path)
+			tracer.RoundTrip(&http.Request{Method: tt.method, URL: u})
+
+			resources := tracer.GetResources()
+			if len(resources) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(resources))
+			}
+			if !reflect.DeepEqual(resources[0].Verbs, []string{tt.expected}) {
+				t.Errorf("expected verbs [%s], got %v", tt.expected, resources[0].Verbs)
+			}
+		})
+	}
+}
+
+func TestRequestVerbWithoutName(t *testing.T) {
+	tests := []struct {
+		method   string
+		query    string
+		expected string
+	}{
+		{method: http.MethodGet, expected: "list"},
+		{method: http.MethodGet, query: "watch=true", expected: "watch"},
+		{method: http.MethodGet, query: "watch=1", expected: "watch"},
+		{method: http.MethodGet, query: "watch=false", expected: "list"},
+		{method: http.MethodPost, query: "dryRun=All", expected: "create"},
+		{method: http.MethodDelete, expected: "deletecollection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.query, func(t *testing.T) {
+			u, _ := url.Parse("/apis/apps/v1/namespaces/default/deployments?" + tt.query)
+			if got := requestVerb(&http.Request{Method: tt.method, URL: u}, "", false); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTracerCollectionPaths(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected resources.Resource
+	}{
+		{
+			path: "/apis/apps/v1/namespaces/x/deployments",
+			expected: resources.Resource{
+				Group:     "apps",
+				Version:   "v1",
+				Resource:  "deployments",
+				Namespace: "x",
+				Verbs:     []string{"list"},
+				Origin:    resources.OriginLookup,
+				Status:    http.StatusOK,
+			},
+		},
+		{
+			path: "/api/v1/nodes",
+			expected: resources.Resource{
+				Version:  "v1",
+				Resource: "nodes",
+				Verbs:    []string{"list"},
+				Origin:   resources.OriginLookup,
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			path: "/api/v1/namespaces/ns/pods",
+			expected: resources.Resource{
+				Version:   "v1",
+				Resource:  "pods",
+				Namespace: "ns",
+				Verbs:     []string{"list"},
+				Origin:    resources.OriginLookup,
+				Status:    http.StatusOK,
+			},
+		},
+		{
+			path: "/api/v1/namespaces",
+			expected: resources.Resource{
+				Version:  "v1",
+				Resource: "namespaces",
+				Verbs:    []string{"list"},
+				Origin:   resources.OriginLookup,
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			path: "/api/v1/namespaces/demo",
+			expected: resources.Resource{
+				Version:  "v1",
+				Resource: "namespaces",
+				Name:     "demo",
+				Verbs:    []string{"get"},
+				Origin:   resources.OriginLookup,
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			path: "/apis/apps/v1/deployments?watch=true",
+			expected: resources.Resource{
+				Group:    "apps",
+				Version:  "v1",
+				Resource: "deployments",
+				Verbs:    []string{"watch"},
+				Origin:   resources.OriginLookup,
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			path: "/api/v1/watch/namespaces/ns/configmaps",
+			expected: resources.Resource{
+				Version:   "v1",
+				Resource:  "configmaps",
+				Namespace: "ns",
+				Verbs:     []string{"watch"},
+				Origin:    resources.OriginLookup,
+				Status:    http.StatusOK,
+			},
+		},
+		{
+			path: "/apis/batch/v1/watch/jobs/my-job",
+			expected: resources.Resource{
+				Group:    "batch",
+				Version:  "v1",
+				Resource: "jobs",
+				Name:     "my-job",
+				Verbs:    []string{"watch"},
+				Origin:   resources.OriginLookup,
+				Status:   http.StatusOK,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			tracer := &Tracer{}
+			tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+			u, _ := url.Parse(tt.path)
+			tracer.RoundTrip(&http.Request{Method: http.MethodGet, URL: u})
+
+			resources := tracer.GetResources()
+			if len(resources) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(resources))
+			}
+			if !reflect.DeepEqual(resources[0], tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, resources[0])
+			}
+		})
+	}
+}
+
+func TestTracerSubresourcePaths(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected resources.Resource
+	}{
+		{
+			method: http.MethodPatch,
+			path:   "/apis/apps/v1/namespaces/ns/deployments/web/scale",
+			expected: resources.Resource{
+				Group:       "apps",
+				Version:     "v1",
+				Resource:    "deployments",
+				Subresource: "scale",
+				Namespace:   "ns",
+				Name:        "web",
+				Verbs:       []string{"patch"},
+				Origin:      resources.OriginRendered,
+				Status:      http.StatusOK,
+			},
+		},
+		{
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/ns/services/svc/proxy/metrics/v1",
+			expected: resources.Resource{
+				Version:     "v1",
+				Resource:    "services",
+				Subresource: "proxy",
+				Namespace:   "ns",
+				Name:        "svc",
+				Verbs:       []string{"get"},
+				Origin:      resources.OriginLookup,
+				Status:      http.StatusOK,
+			},
+		},
+		{
+			method: http.MethodPost,
+			path:   "/api/v1/namespaces/ns/pods/web-0/exec",
+			expected: resources.Resource{
+				Version:     "v1",
+				Resource:    "pods",
+				Subresource: "exec",
+				Namespace:   "ns",
+				Name:        "web-0",
+				Verbs:       []string{"create"},
+				Origin:      resources.OriginRendered,
+				Status:      http.StatusOK,
+			},
+		},
+		{
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/ns/pods/web-0/log",
+			expected: resources.Resource{
+				Version:     "v1",
+				Resource:    "pods",
+				Subresource: "log",
+				Namespace:   "ns",
+				Name:        "web-0",
+				Verbs:       []string{"get"},
+				Origin:      resources.OriginLookup,
+				Status:      http.StatusOK,
+			},
+		},
+		{
+			method: http.MethodPut,
+			path:   "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/foos.example.com/status",
+			expected: resources.Resource{
+				Group:       "apiextensions.k8s.io",
+				Version:     "v1",
+				Resource:    "customresourcedefinitions",
+				Subresource: "status",
+				Name:        "foos.example.com",
+				Verbs:       []string{"update"},
+				Origin:      resources.OriginDiscovery,
+				Status:      http.StatusOK,
+			},
+		},
+		{
+			method: http.MethodPut,
+			path:   "/api/v1/namespaces/demo/finalize",
+			expected: resources.Resource{
+				Version:     "v1",
+				Resource:    "namespaces",
+				Subresource: "finalize",
+				Name:        "demo",
+				Verbs:       []string{"update"},
+				Origin:      resources.OriginRendered,
+				Status:      http.StatusOK,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			tracer := &Tracer{}
+			tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+			u, _ := url.Parse(tt.path)
+			tracer.RoundTrip(&http.Request{Method: tt.method, URL: u})
+
+			resources := tracer.GetResources()
+			if len(resources) != 1 {
+				t.Fatalf("expected 1 resource, got %d", len(resources))
+			}
+			if !reflect.DeepEqual(resources[0], tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, resources[0])
+			}
+		})
+	}
+}
+
+func TestTracerGetAggregatedResources(t *testing.T) {
+	tracer := &Tracer{}
+	tracer.WithRoundTripper(&NoOpRoundTripper{})
+
+	calls := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/apis/apps/v1/namespaces/default/deployments/web"},
+		{method: http.MethodGet, path: "/api/v1/namespaces/default/configmaps/cfg"},
+		{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/default/deployments/web"},
+		{method: http.MethodGet, path: "/apis/apps/v1/namespaces/default/deployments/web"},
+		{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/default/deployments/web/scale"},
+		{method: http.MethodGet, path: "/apis/apps/v1/namespaces/other/deployments/web"},
+	}
+	for _, c := range calls {
+		u, _ := url.Parse(c.path)
+		tracer.RoundTrip(&http.Request{Method: c.method, URL: u})
+	}
+
+	expected := []resources.Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "default", Name: "web", Verbs: []string{"get", "patch"}, Origin: resources.OriginRendered, Status: http.StatusOK, Count: 3},
+		{Version: "v1", Resource: "configmaps", Namespace: "default", Name: "cfg", Verbs: []string{"get"}, Origin: resources.OriginLookup, Status: http.StatusOK, Count: 1},
+		{Group: "apps", Version: "v1", Resource: "deployments", Subresource: "scale", Namespace: "default", Name: "web", Verbs: []string{"patch"}, Origin: resources.OriginRendered, Status: http.StatusOK, Count: 1},
+		{Group: "apps", Version: "v1", Resource: "deployments", Namespace: "other", Name: "web", Verbs: []string{"get"}, Origin: resources.OriginLookup, Status: http.StatusOK, Count: 1},
+	}
+
+	got := tracer.GetAggregatedResources()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	// Aggregating an aggregated result must be a no-op.
+	if again := Aggregate(got); !reflect.DeepEqual(again, expected) {
+		t.Errorf("expected re-aggregation to be stable, got %+v", again)
+	}
+
+	// The raw entries must not be affected.
+	if raw := tracer.GetResources(); len(raw) != len(calls) {
+		t.Errorf("expected %d raw resources, got %d", len(calls), len(raw))
+	}
+}
+
+func TestAggregateEmpty(t *testing.T) {
+	if got := Aggregate(nil); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty non-nil slice, got %#v", got)
+	}
+}
@@ -0,0 +1,173 @@
+// Package values annotates the values a chart is rendered with by where they
+// come from, redacting those that look sensitive.
+package values
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Sources of a value.
+const (
+	// SourceChart is a default of the chart or of one of its subcharts.
+	SourceChart = "chart"
+	// SourceComposition is a value of the spec of the composition.
+	SourceComposition = "composition"
+	// SourceGlobal is a global value the composition-dynamic-controller
+	// injects.
+	SourceGlobal = "global"
+)
+
+// Redacted replaces the values that look sensitive.
+const Redacted = "<redacted>"
+
+var (
+	// sensitiveKey matches the keys whose values look sensitive.
+	sensitiveKey = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api[-_]?key|private[-_]?key|credential)`)
+	// pemBlock matches the values holding a PEM block, e.g. a private key.
+	pemBlock = regexp.MustCompile(`-----BEGIN [A-Z ]+-----`)
+)
+
+// Annotated are the values of a chart with the source of each of them.
+type Annotated struct {
+	// Values are the values, with those that look sensitive replaced by
+	// Redacted.
+	Values map[string]any `json:"values"`
+	// Sources is the source of every value, by path. A path joins the keys
+	// leading to the value with dots, escaping the dots of a key with a
+	// backslash, as helm --set does. Lists are values as a whole.
+	Sources map[string]string `json:"sources"`
+	// Redacted are the paths of the redacted values, sorted.
+	Redacted []string `json:"redacted,omitempty"`
+}
+
+// Annotate returns vals, the values a chart is rendered with, with the source
+// of each of them: spec are the values of the composition and global the
+// global values injected into them, while the others are defaults of the
+// chart. The global values Helm copies to every subchart keep the source of
+// those of the chart. vals is not modified.
+func Annotate(vals, spec, global map[string]any) Annotated {
+	res := Annotated{Sources: map[string]string{}}
+	res.Values = annotate(vals, nil, func(path []string, v any) (string, bool) {
+		return sourceOf(path, v, vals, spec, global), sensitive(path[len(path)-1], v)
+	}, &res)
+	sort.Strings(res.Redacted)
+	return res
+}
+
+// annotate returns a copy of m, the map at path, calling fn for each of its
+// values that is not a non-empty map to tell its source and whether it is
+// redacted.
+func annotate(m map[string]any, path []string, fn func(path []string, v any) (string, bool), res *Annotated) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		p := append(path[:len(path):len(path)], k)
+		if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+			out[k] = annotate(sub, p, fn, res)
+			continue
+		}
+
+		source, redact := fn(p, v)
+		res.Sources[pathOf(p)] = source
+		if redact {
+			v = Redacted
+			res.Redacted = append(res.Redacted, pathOf(p))
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func sourceOf(path []string, v any, vals, spec, global map[string]any) string {
+	if path[0] == "global" && len(path) > 1 {
+		if _, ok := lookup(global, path[1:]); ok {
+			return SourceGlobal
+		}
+	}
+	// A global value of a subchart is a copy of the one of its parent.
+	for i := len(path) - 2; i > 0; i-- {
+		if path[i] != "global" {
+			continue
+		}
+		top := append([]string{"global"}, path[i+1:]...)
+		if w, ok := lookup(vals, top); ok && reflect.DeepEqual(v, w) {
+			return sourceOf(top, v, vals, spec, global)
+		}
+		break
+	}
+	if _, ok := lookup(spec, path); ok {
+		return SourceComposition
+	}
+	return SourceChart
+}
+
+// lookup returns the value at path in m.
+func lookup(m map[string]any, path []string) (any, bool) {
+	var v any = m
+	for _, k := range path {
+		el, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = el[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// sensitive tells whether the value v of key looks sensitive: a value of a
+// key naming a password, a secret, a token or alike, a PEM block, or a list
+// holding one of them.
+func sensitive(key string, v any) bool {
+	switch el := v.(type) {
+	case nil, bool:
+		return false
+	case string:
+		if el == "" {
+			return false
+		}
+		if pemBlock.MatchString(el) {
+			return true
+		}
+	case []any:
+		if len(el) == 0 {
+			return false
+		}
+		// A list is a value as a whole: it is redacted when any of its
+		// elements holds a sensitive value.
+		if slices.ContainsFunc(el, func(item any) bool { return sensitiveItem(key, item) }) {
+			return true
+		}
+	case map[string]any:
+		return false
+	}
+	return sensitiveKey.MatchString(key)
+}
+
+// sensitiveItem tells whether the item v of the list of key holds a value
+// that looks sensitive, itself or, for a map, any of its values at any depth.
+func sensitiveItem(key string, v any) bool {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return sensitive(key, v)
+	}
+	for k, w := range m {
+		if sensitiveItem(k, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathOf returns the path of the keys of path.
+func pathOf(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = strings.ReplaceAll(k, ".", `\.`)
+	}
+	return strings.Join(keys, ".")
+}
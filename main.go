@@ -16,15 +16,25 @@ import (
 	_ "github.com/krateoplatformops/chart-inspector/docs"
 	"github.com/krateoplatformops/chart-inspector/internal/handlers"
 	"github.com/krateoplatformops/chart-inspector/internal/handlers/health"
+	diffrbac "github.com/krateoplatformops/chart-inspector/internal/handlers/rbac/diff"
+	getrbac "github.com/krateoplatformops/chart-inspector/internal/handlers/rbac/get"
+	verifyrbac "github.com/krateoplatformops/chart-inspector/internal/handlers/rbac/verify"
+	getrender "github.com/krateoplatformops/chart-inspector/internal/handlers/render/get"
 	getresources "github.com/krateoplatformops/chart-inspector/internal/handlers/resources/get"
+	getvalues "github.com/krateoplatformops/chart-inspector/internal/handlers/values/get"
+	"github.com/krateoplatformops/chart-inspector/internal/rbac"
+	"github.com/krateoplatformops/chart-inspector/internal/tracer"
 	"github.com/krateoplatformops/plumbing/env"
 	"github.com/krateoplatformops/plumbing/helm/getter/cache"
 	helmv3 "github.com/krateoplatformops/plumbing/helm/v3"
 	"github.com/krateoplatformops/plumbing/logger"
 	plurals "github.com/krateoplatformops/unstructured-runtime/pkg/pluralizer"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -42,6 +52,16 @@ func main() {
 	kubeconfig := flag.String("kubeconfig", env.String("KUBECONFIG", ""),
 		"absolute path to the kubeconfig file")
 	krateoNamespace := env.String("KRATEO_NAMESPACE", "krateo-system")
+	cassetteDir := flag.String("cassette-dir", env.String("CASSETTE_DIR", ""),
+		"directory where dry-runs are recorded to and replayed from (disabled if empty)")
+	maxCalls := flag.Int("max-calls", env.Int("MAX_CALLS", 10000),
+		"maximum number of API calls recorded per inspection (0 means no limit)")
+	maxBodyBytes := flag.Int("max-body-bytes", env.Int("MAX_BODY_BYTES", 64<<20),
+		"maximum number of body bytes captured per inspection (0 means no limit)")
+	applyRBAC := flag.Bool("apply-rbac", env.Bool("APPLY_RBAC", false),
+		"allow POST /rbac to apply the generated RBAC to the cluster")
+	rbacGCInterval := flag.Duration("rbac-gc-interval", env.Duration("RBAC_GC_INTERVAL", 10*time.Minute),
+		"interval between the deletions of the applied RBAC whose CompositionDefinition is gone, with -apply-rbac")
 
 	flag.Parse()
 
@@ -78,15 +98,26 @@ func main() {
 
 	pluralizer := plurals.New()
 
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		log.Error("Creating discovery client.", "error", err)
+		os.Exit(1)
+	}
+	// The mapper refreshes its cache when asked for an unknown resource, e.g.
+	// one installed by a CRD after startup.
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	cacheOpts := []cache.Option{
+		cache.WithCleanupInterval(5 * time.Minute),
+		cache.WithTTL(1 * time.Hour),
+	}
+
 	// Initialize Helm client with global cache and CRD informer
 	helmClient, err := helmv3.NewClient(cfg,
 		helmv3.WithLogger(func(format string, v ...interface{}) {
 			log.Debug(fmt.Sprintf(format, v...))
 		}),
-		helmv3.WithCache(
-			cache.WithCleanupInterval(5*time.Minute),
-			cache.WithTTL(1*time.Hour),
-		),
+		helmv3.WithCache(cacheOpts...),
 		helmv3.WithCRDInformer(cfg, 30*time.Minute),
 	)
 	if err != nil {
@@ -94,13 +125,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Charts pulled without the Helm client, e.g. for their values or to
+	// render their hooks, share the directory of its cache.
+	chartCache, err := cache.NewDiskCache(cacheOpts...)
+	if err != nil {
+		log.Error("Creating chart cache.", "error", err)
+		os.Exit(1)
+	}
+
 	opts := handlers.HandlerOptions{
 		Log:             log,
 		DynamicClient:   dyn,
 		KrateoNamespace: krateoNamespace,
 		RestConfig:      cfg,
 		Plurarizer:      pluralizer,
+		RESTMapper:      mapper,
 		HelmClient:      helmClient,
+		ChartCache:      chartCache,
+		CassetteDir:     *cassetteDir,
+		Limits: tracer.Limits{
+			MaxCalls:     *maxCalls,
+			MaxBodyBytes: *maxBodyBytes,
+		},
+		ApplyRBAC: *applyRBAC,
 	}
 
 	healthy := int32(0)
@@ -108,6 +155,11 @@ func main() {
 	mux.Handle("/healthz", health.Live())
 	mux.Handle("/readyz", health.Ready(&healthy))
 	mux.Handle("/resources", getresources.GetResources(opts))
+	mux.Handle("/rbac", getrbac.GetRBAC(opts))
+	mux.Handle("/rbac/verify", verifyrbac.VerifyRBAC(opts))
+	mux.Handle("/rbac/diff", diffrbac.DiffRBAC(opts))
+	mux.Handle("/render", getrender.GetRender(opts))
+	mux.Handle("/values", getvalues.GetValues(opts))
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
 
 	server := &http.Server{
@@ -128,6 +180,10 @@ func main() {
 	}...)
 	defer stop()
 
+	if *applyRBAC && *rbacGCInterval > 0 {
+		go collectRBAC(ctx, dyn, *rbacGCInterval, log)
+	}
+
 	go func() {
 		atomic.StoreInt32(&healthy, 1)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -163,3 +219,27 @@ func main() {
 
 	log.Info("server gracefully stopped")
 }
+
+// collectRBAC deletes the applied RBAC whose CompositionDefinition is gone
+// every interval, until ctx is done: the garbage collector only deletes the
+// objects in the namespace of the CompositionDefinition.
+func collectRBAC(ctx context.Context, dyn dynamic.Interface, interval time.Duration, log *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := rbac.Collect(ctx, dyn)
+		if err != nil {
+			log.Error("unable to collect applied rbac", slog.Any("error", err))
+		}
+		if deleted > 0 {
+			log.Info("Collected applied rbac", slog.Int("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}